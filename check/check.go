@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package check
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+const (
+	defaultPageSize = 25
+	maxPages        = 20
+)
+
+// Run detects new versions of the resource: the executions of the configured
+// pipeline since the version in the request.
+func Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	var request concourse.CheckRequest
+	err := concourse.ReadRequest(stdin, &request)
+	if err != nil {
+		return err
+	}
+
+	spinClient, err := spinnaker.NewClient(ctx, request.Source)
+	if err != nil {
+		return err
+	}
+
+	pageSize := request.Source.CheckPageSize
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	pipelineExecutions, err := getPipelineExecutionsSince(ctx, spinClient, request.Version.Ref, request.Source.Statuses, pageSize)
+	if err != nil {
+		return err
+	}
+
+	res := concourse.CheckResponse{}
+	for _, execution := range pipelineExecutions {
+		res = append(res, concourse.Version{Ref: execution.ID})
+	}
+	return concourse.WriteResponse(stdout, res)
+}
+
+// returns the executions of the pipeline from the execution of the previous version onwards, oldest first.
+// Gate only returns the latest executions, so the limit grows by a page at a time until the execution of the
+// previous version is found or the executions reach back past its build time, for at most maxPages pages.
+// Without a previous version, or when it no longer exists, only the latest execution is returned.
+func getPipelineExecutionsSince(ctx context.Context, spinClient spinnaker.SpinClient, ref string, statuses []string, pageSize int) ([]spinnaker.PipelineExecution, error) {
+	query := spinnaker.ExecutionQuery{
+		Statuses: statuses,
+		Limit:    pageSize,
+	}
+	var previous *spinnaker.PipelineExecution
+	for page := 1; ; page++ {
+		pipelineExecutions, err := spinClient.GetPipelineExecutions(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(pipelineExecutions, func(i, j int) bool {
+			return pipelineExecutions[i].BuildTime < pipelineExecutions[j].BuildTime
+		})
+		if ref == "" {
+			return latest(pipelineExecutions), nil
+		}
+		for i, execution := range pipelineExecutions {
+			if execution.ID == ref {
+				return pipelineExecutions[i:], nil
+			}
+		}
+
+		// the previous version is missing when it was deleted, or when its status no longer matches the statuses
+		if previous == nil {
+			execution, err := spinClient.GetPipelineExecution(ctx, ref)
+			if errors.Is(err, spinnaker.ErrExecutionNotFound) {
+				return latest(pipelineExecutions), nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			previous = &execution
+		}
+		exhausted := len(pipelineExecutions) < query.Limit || page == maxPages
+		if exhausted || pipelineExecutions[0].BuildTime < previous.BuildTime {
+			return builtAfter(pipelineExecutions, previous.BuildTime), nil
+		}
+		query.Limit += pageSize
+	}
+}
+
+func latest(pipelineExecutions []spinnaker.PipelineExecution) []spinnaker.PipelineExecution {
+	if len(pipelineExecutions) == 0 {
+		return nil
+	}
+	return pipelineExecutions[len(pipelineExecutions)-1:]
+}
+
+func builtAfter(pipelineExecutions []spinnaker.PipelineExecution, buildTime int64) []spinnaker.PipelineExecution {
+	for i, execution := range pipelineExecutions {
+		if execution.BuildTime > buildTime {
+			return pipelineExecutions[i:]
+		}
+	}
+	return nil
+}
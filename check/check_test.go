@@ -0,0 +1,192 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package check_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/pivotal-cf/spinnaker-resource/check"
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker/gatetest"
+)
+
+var _ = Describe("Check", func() {
+	var (
+		spinnakerServer *ghttp.Server
+		pipeline        *gatetest.Pipeline
+		request         concourse.CheckRequest
+		rawRequest      []byte
+		stdout, stderr  *bytes.Buffer
+		runErr          error
+	)
+
+	BeforeEach(func() {
+		pipeline = gatetest.NewPipeline("bar", "foo")
+		spinnakerServer = ghttp.NewServer()
+		spinnakerServer.AppendHandlers(pipeline.ClientHandlers()...)
+		request = concourse.CheckRequest{
+			Source: pipeline.Source(spinnakerServer.URL()),
+		}
+		rawRequest = nil
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+	})
+
+	JustBeforeEach(func() {
+		if rawRequest == nil {
+			var err error
+			rawRequest, err = json.Marshal(request)
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		runErr = check.Run(context.Background(), bytes.NewBuffer(rawRequest), stdout, stderr, nil)
+	})
+
+	AfterEach(func() {
+		spinnakerServer.Close()
+	})
+
+	Context("when there are executions since the requested version", func() {
+		BeforeEach(func() {
+			request.Version = concourse.Version{Ref: "EX2"}
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds=foo-config-id"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+						{"id": "EX3", "buildTime": 3},
+						{"id": "EX2", "buildTime": 2},
+						{"id": "EX1", "buildTime": 1},
+					}),
+				),
+			)
+		})
+
+		It("writes the requested version and every version after it", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			var response concourse.CheckResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response).To(Equal(concourse.CheckResponse{{Ref: "EX2"}, {Ref: "EX3"}}))
+		})
+	})
+
+	Context("when the pipeline has no executions", func() {
+		BeforeEach(func() {
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds=foo-config-id"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{}),
+				),
+			)
+		})
+
+		It("writes an empty list of versions", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stdout.String()).To(MatchJSON("[]"))
+		})
+	})
+
+	Context("when the requested version is missing from the executions with the statuses", func() {
+		BeforeEach(func() {
+			request.Version = concourse.Version{Ref: "EX2"}
+			request.Source.Statuses = []string{"RUNNING"}
+			request.Source.CheckPageSize = 2
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=2&pipelineConfigIds=foo-config-id&statuses=RUNNING"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+						{"id": "EX5", "buildTime": 5, "status": "RUNNING"},
+						{"id": "EX4", "buildTime": 4, "status": "RUNNING"},
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX2"),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "EX2", "buildTime": 2, "status": "SUCCEEDED"}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=4&pipelineConfigIds=foo-config-id&statuses=RUNNING"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+						{"id": "EX5", "buildTime": 5, "status": "RUNNING"},
+						{"id": "EX4", "buildTime": 4, "status": "RUNNING"},
+						{"id": "EX3", "buildTime": 3, "status": "RUNNING"},
+						{"id": "EX1", "buildTime": 1, "status": "RUNNING"},
+					}),
+				),
+			)
+		})
+
+		It("stops paging once the executions reach back past it and writes the versions after it", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(5))
+
+			var response concourse.CheckResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response).To(Equal(concourse.CheckResponse{{Ref: "EX3"}, {Ref: "EX4"}, {Ref: "EX5"}}))
+		})
+	})
+
+	Context("when the requested version has been deleted", func() {
+		BeforeEach(func() {
+			request.Version = concourse.Version{Ref: "EX1"}
+			request.Source.CheckPageSize = 2
+			spinnakerServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+					{"id": "EX3", "buildTime": 3},
+					{"id": "EX2", "buildTime": 2},
+				}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWith(404, ""),
+				),
+			)
+		})
+
+		It("writes the latest version without paging through the history", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(4))
+
+			var response concourse.CheckResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response).To(Equal(concourse.CheckResponse{{Ref: "EX3"}}))
+		})
+	})
+
+	Context("when spinnaker fails to list the executions", func() {
+		BeforeEach(func() {
+			spinnakerServer.AppendHandlers(ghttp.RespondWith(500, "boom"))
+		})
+
+		It("returns the api error and writes no versions", func() {
+			var apiErr *spinnaker.APIError
+			Expect(errors.As(runErr, &apiErr)).To(BeTrue())
+			Expect(apiErr.StatusCode).To(Equal(500))
+			Expect(stdout.Len()).To(BeZero())
+		})
+	})
+
+	Context("when the request is not valid json", func() {
+		BeforeEach(func() {
+			rawRequest = []byte("{")
+		})
+
+		It("returns an error", func() {
+			Expect(runErr).To(HaveOccurred())
+			Expect(runErr.Error()).To(ContainSubstring("error reading request"))
+		})
+	})
+})
@@ -12,32 +12,42 @@ package concourse
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/mitchellh/colorstring"
 )
 
-func Fatal(doing string, err error) {
-	Sayf(colorstring.Color("[red]error %s: %s\n"), doing, err)
-	//TODO: don't exit here, let the caller decide.
+// Fatal reports the error of a failed step to stderr and exits, it is only
+// meant to be called from main.
+func Fatal(doing string, err error, hints ...string) {
+	Sayf(os.Stderr, colorstring.Color("[red]error %s: %s\n"), doing, err)
+	for _, hint := range hints {
+		if hint != "" {
+			Sayf(os.Stderr, colorstring.Color("[yellow]hint: %s\n"), hint)
+		}
+	}
 	os.Exit(1)
 }
 
-func Sayf(message string, args ...interface{}) {
-	fmt.Fprintf(os.Stderr, message, args...)
+func Sayf(w io.Writer, message string, args ...interface{}) {
+	fmt.Fprintf(w, message, args...)
 }
 
-//TODO refactor this and don't exit in this function, instead return control to caller with err msg
-func ReadRequest(request interface{}) {
-	if err := json.NewDecoder(os.Stdin).Decode(request); err != nil {
-		Fatal("Error reading request: %v\n", err)
+// ReadRequest decodes the request, keeping numbers in untyped values such as
+// trigger_params as json.Number so they reach spinnaker exactly as given.
+func ReadRequest(r io.Reader, request interface{}) error {
+	decoder := json.NewDecoder(r)
+	decoder.UseNumber()
+	if err := decoder.Decode(request); err != nil {
+		return fmt.Errorf("error reading request: %s", err)
 	}
+	return nil
 }
 
-//TODO refactor this and don't exit in this function, instead return control to caller with err msg
-func WriteResponse(response interface{}) {
-	if err := json.NewEncoder(os.Stdout).Encode(response); err != nil {
-		Fatal("Error writing response: %v\n", err)
+func WriteResponse(w io.Writer, response interface{}) error {
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		return fmt.Errorf("error writing response: %s", err)
 	}
-	os.Exit(0)
+	return nil
 }
@@ -13,11 +13,31 @@ type Source struct {
 	SpinnakerAPI         string   `json:"spinnaker_api"`
 	SpinnakerApplication string   `json:"spinnaker_application"`
 	SpinnakerPipeline    string   `json:"spinnaker_pipeline"`
+	SpinnakerUIURL       string   `json:"spinnaker_ui_url"`
 	Statuses             []string `json:"statuses"`
 	StatusCheckTimeout   string   `json:"status_check_timeout"`
 	StatusCheckInterval  string   `json:"status_check_interval"`
+	CheckPageSize        int      `json:"check_page_size"`
+	RetryAttempts        int      `json:"retry_attempts"`
+	RetryBaseDelay       string   `json:"retry_base_delay"`
+	RetryMaxDelay        string   `json:"retry_max_delay"`
 	X509Cert             string   `json:"spinnaker_x509_cert"`
 	X509Key              string   `json:"spinnaker_x509_key"`
+	CACert               string   `json:"spinnaker_ca_cert"`
+	ServerName           string   `json:"spinnaker_server_name"`
+	InsecureSkipVerify   bool     `json:"insecure_skip_verify"`
+	Auth                 Auth     `json:"auth"`
+}
+
+type Auth struct {
+	Type         string   `json:"type"`          // optional, x509 (default), bearer, oauth2, basic or none
+	Token        string   `json:"token"`         // bearer
+	TokenURL     string   `json:"token_url"`     // oauth2
+	ClientID     string   `json:"client_id"`     // oauth2
+	ClientSecret string   `json:"client_secret"` // oauth2
+	Scopes       []string `json:"scopes"`        // oauth2, optional
+	Username     string   `json:"username"`      // basic
+	Password     string   `json:"password"`      // basic
 }
 
 type Version struct {
@@ -30,19 +50,59 @@ type MetadataPair struct {
 }
 
 type OutParams struct {
-	TriggerParams             map[string]string `json:"trigger_params,omitempty"` // optional
-	Artifacts                 string            `json:"artifacts_json_file"`      // optional
-	TriggerParamsJSONFilePath string            `json:"trigger_params_json_file"` //optional
+	TriggerParams              map[string]interface{} `json:"trigger_params,omitempty"`       // optional
+	Artifacts                  string                 `json:"artifacts_json_file"`            // optional
+	TriggerParamsJSONFilePath  string                 `json:"trigger_params_json_file"`       //optional
+	TriggerParamsFiles         []string               `json:"trigger_params_files"`           // optional
+	TriggerParamsFormat        string                 `json:"trigger_params_format"`          // optional: json, yaml or dotenv, defaults to the file extension
+	StrictParams               bool                   `json:"strict_params"`                  // optional
+	ArtifactList               []Artifact             `json:"artifacts"`                      // optional
+	EmbedArtifacts             map[string]string      `json:"embed_artifacts"`                // optional: artifact name to path or glob
+	EmbedArtifactsMaxSize      int64                  `json:"embed_artifacts_max_size"`       // optional: bytes per file, defaults to 1MiB
+	EmbedArtifactsMaxTotalSize int64                  `json:"embed_artifacts_max_total_size"` // optional: base64 encoded bytes of all files, defaults to 4MiB
+	OnTimeout                  string                 `json:"on_timeout"`                     // optional: cancel, pause or leave (default)
+	CancelReason               string                 `json:"cancel_reason"`                  // optional
+	Action                     string                 `json:"action"`                         // optional: trigger (default), cancel, pause, resume or judge
+	ExecutionIDFile            string                 `json:"execution_id_file"`              // optional
+	JudgmentStatus             string                 `json:"judgment_status"`                // required for judge: continue or stop
+	JudgmentStage              string                 `json:"judgment_stage"`                 // optional: stage name or refId
+	JudgmentInput              string                 `json:"judgment_input"`                 // optional
+	WaitForStage               string                 `json:"wait_for_stage"`                 // optional: stage name or refId
+	WaitForStageStatuses       []string               `json:"wait_for_stage_statuses"`        // optional: defaults to SUCCEEDED
+}
+
+// An artifact to trigger a pipeline with, built by put from the values given
+// or read from files in the sources directory.
+type Artifact struct {
+	Type            string `json:"type"`             // e.g. docker/image, gcs/object, s3/object, github/file or embedded/base64
+	Name            string `json:"name"`             // optional
+	NameFile        string `json:"name_file"`        // optional
+	Version         string `json:"version"`          // optional
+	VersionFile     string `json:"version_file"`     // optional
+	Reference       string `json:"reference"`        // optional
+	ReferenceFile   string `json:"reference_file"`   // optional
+	Location        string `json:"location"`         // optional
+	ArtifactAccount string `json:"artifact_account"` // optional
+	From            string `json:"from"`             // optional, docker/image: the directory of a docker image resource
+	File            string `json:"file"`             // embedded/base64: the file to embed
 }
 
 type CheckRequest struct {
 	Source  Source `json:"source"`
 	Version `json:"version"`
 }
+type InParams struct {
+	FetchArtifacts    bool     `json:"fetch_artifacts"`     // optional
+	WaitForCompletion bool     `json:"wait_for_completion"` // optional
+	Timeout           string   `json:"timeout"`             // optional
+	Interval          string   `json:"interval"`            // optional
+	ExpectedStatuses  []string `json:"expected_statuses"`   // optional
+}
+
 type InRequest struct {
-	Source  Source    `json:"source"`
-	Version Version   `json:"version"`
-	Params  OutParams `json:"params"`
+	Source  Source   `json:"source"`
+	Version Version  `json:"version"`
+	Params  InParams `json:"params"`
 }
 type OutRequest struct {
 	Source Source    `json:"source"`
@@ -61,14 +121,6 @@ type InResponseMetadata struct {
 	Value string `json:"value"`
 }
 
-type IntermediateMetadata struct {
-	PipelineName    string `json:"name"`
-	ApplicationName string `json:"application"`
-	StartTime       int64  `json:"startTime"`
-	EndTime         int64  `json:"endTime"`
-	Status          string `json:"status"`
-}
-
 type InResponse struct {
 	Version  `json:"version"`
 	Metadata []InResponseMetadata `json:"metadata"`
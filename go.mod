@@ -1,7 +1,10 @@
 module github.com/pivotal-cf/spinnaker-resource
 
+go 1.13
+
 require (
 	github.com/mitchellh/colorstring v0.0.0-20150917214807-8631ce90f286
 	github.com/onsi/ginkgo v1.6.0
 	github.com/onsi/gomega v1.4.2
+	gopkg.in/yaml.v2 v2.2.1
 )
@@ -0,0 +1,225 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package in
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+// stageSummary is the flattened form of a stage written to stages.json.
+type stageSummary struct {
+	ID            string                    `json:"id"`
+	RefID         string                    `json:"refId"`
+	Name          string                    `json:"name"`
+	Type          string                    `json:"type"`
+	Status        spinnaker.ExecutionStatus `json:"status"`
+	StartTime     int64                     `json:"startTime"`
+	EndTime       int64                     `json:"endTime"`
+	ParentStageID string                    `json:"parentStageId,omitempty"`
+	OutputsDir    string                    `json:"outputsDir,omitempty"`
+}
+
+// writeExecutionFiles writes the parts of the execution downstream tasks
+// usually need as individual files, so they do not have to parse
+// metadata.json.
+func writeExecutionFiles(dest string, pipelineExecution spinnaker.PipelineExecution) error {
+	err := writeFile(filepath.Join(dest, "status"), []byte(pipelineExecution.Status))
+	if err != nil {
+		return err
+	}
+
+	parameters := pipelineExecution.Trigger.Parameters
+	if parameters == nil {
+		parameters = map[string]interface{}{}
+	}
+	err = writeJSON(filepath.Join(dest, "trigger", "parameters.json"), parameters)
+	if err != nil {
+		return err
+	}
+	for key, value := range parameters {
+		err = writeValue(filepath.Join(dest, "trigger", "parameters", fileName(key)), value)
+		if err != nil {
+			return err
+		}
+	}
+
+	stageNames := map[string]int{}
+	for _, stage := range pipelineExecution.Stages {
+		stageNames[stage.Name]++
+	}
+
+	stages := []stageSummary{}
+	for _, stage := range pipelineExecution.Stages {
+		summary := stageSummary{
+			ID:            stage.ID,
+			RefID:         stage.RefID,
+			Name:          stage.Name,
+			Type:          stage.Type,
+			Status:        stage.Status,
+			StartTime:     stage.StartTime,
+			EndTime:       stage.EndTime,
+			ParentStageID: stage.ParentStageID,
+		}
+		if len(stage.Outputs) > 0 {
+			summary.OutputsDir = filepath.Join("outputs", outputsDirName(stage, stageNames[stage.Name] > 1))
+		}
+		stages = append(stages, summary)
+
+		for key, value := range stage.Outputs {
+			err = writeValue(filepath.Join(dest, summary.OutputsDir, fileName(key)), value)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return writeJSON(filepath.Join(dest, "stages.json"), stages)
+}
+
+// outputsDirName names the outputs directory of a stage after the stage, or
+// after its refId when it has no name. When several stages share the name,
+// the refId is added as in "Deploy [2]" to keep the directories apart.
+func outputsDirName(stage spinnaker.Stage, shared bool) string {
+	id := stage.RefID
+	if id == "" {
+		id = stage.ID
+	}
+	switch {
+	case stage.Name == "":
+		return fileName(id)
+	case shared:
+		return fileName(fmt.Sprintf("%s [%s]", stage.Name, id))
+	default:
+		return fileName(stage.Name)
+	}
+}
+
+// artifactFile is the file the contents of an artifact are fetched into,
+// relative to the destination, as listed in artifact_files.json. Index is
+// the position of the artifact in artifacts.json.
+type artifactFile struct {
+	Index   int    `json:"index"`
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	File    string `json:"file"`
+}
+
+// artifactFiles names a file in artifacts/ after each artifact that has a
+// name or reference and can be fetched. When several artifacts share a name
+// the version is added, as in "app@v2", and the index of the artifact when
+// that is not enough.
+func artifactFiles(artifacts []spinnaker.Artifact) []artifactFile {
+	names := map[string]int{}
+	for _, artifact := range artifacts {
+		names[artifactName(artifact)]++
+	}
+
+	files := []artifactFile{}
+	taken := map[string]bool{}
+	for i, artifact := range artifacts {
+		name := artifactName(artifact)
+		if name == "" || !fetchable(artifact) {
+			continue
+		}
+
+		fileBase := name
+		if names[name] > 1 && artifact.Version != "" {
+			fileBase += "@" + artifact.Version
+		}
+		file := filepath.Join("artifacts", fileName(fileBase))
+		if taken[file] {
+			file = filepath.Join("artifacts", fileName(fmt.Sprintf("%s [%d]", fileBase, i)))
+		}
+		taken[file] = true
+		files = append(files, artifactFile{Index: i, Name: name, Version: artifact.Version, File: file})
+	}
+	return files
+}
+
+// fetchable reports whether clouddriver can fetch the contents of the
+// artifact. No artifact account serves docker images or the manifests
+// deployed by kubernetes stages, so asking for them only fails.
+func fetchable(artifact spinnaker.Artifact) bool {
+	return artifact.Type != "docker/image" && !strings.HasPrefix(artifact.Type, "kubernetes/")
+}
+
+func artifactName(artifact spinnaker.Artifact) string {
+	if artifact.Name != "" {
+		return artifact.Name
+	}
+	return artifact.Reference
+}
+
+// fetchArtifacts downloads the contents of the artifacts into the files
+// named by artifactFiles and lists those files in artifact_files.json. It
+// reports the artifacts it skips because they cannot be fetched.
+func fetchArtifacts(ctx context.Context, spinClient spinnaker.SpinClient, stderr io.Writer, dest string, artifacts []spinnaker.Artifact) error {
+	for _, artifact := range artifacts {
+		if !fetchable(artifact) {
+			concourse.Sayf(stderr, "Skipping artifact %s: %s artifacts cannot be fetched\n", artifactName(artifact), artifact.Type)
+		}
+	}
+
+	files := artifactFiles(artifacts)
+	for _, file := range files {
+		concourse.Sayf(stderr, "Fetching artifact: %s\n", file.Name)
+		contents, err := spinClient.FetchArtifact(ctx, artifacts[file.Index])
+		if err != nil {
+			return fmt.Errorf("unable to fetch artifact %s: %w", file.Name, err)
+		}
+		err = writeFile(filepath.Join(dest, file.File), contents)
+		if err != nil {
+			return err
+		}
+	}
+	return writeJSON(filepath.Join(dest, "artifact_files.json"), files)
+}
+
+// writeValue writes strings as they are and anything else as JSON.
+func writeValue(path string, value interface{}) error {
+	if s, ok := value.(string); ok {
+		return writeFile(path, []byte(s))
+	}
+	return writeJSON(path, value)
+}
+
+func writeJSON(path string, value interface{}) error {
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return writeFile(path, contents)
+}
+
+func writeFile(path string, contents []byte) error {
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, contents, 0644)
+}
+
+// fileName makes a stage name or key usable as a single path element.
+func fileName(name string) string {
+	name = strings.Replace(name, string(filepath.Separator), "_", -1)
+	if name == "." || name == ".." {
+		return strings.Replace(name, ".", "_", -1)
+	}
+	return name
+}
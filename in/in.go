@@ -0,0 +1,166 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package in
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/poll"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+const defaultPollingInterval = "10s"
+const defaultPollingTimeout = "30m"
+
+// Run fetches the pipeline execution of the requested version into the
+// destination directory given as the first argument, along with the contents
+// of its artifacts when fetch_artifacts is set. With wait_for_completion it
+// first waits for the execution to finish.
+func Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("destination path not specified")
+	}
+
+	var request concourse.InRequest
+	err := concourse.ReadRequest(stdin, &request)
+	if err != nil {
+		return err
+	}
+
+	spinClient, err := spinnaker.NewClient(ctx, request.Source)
+	if err != nil {
+		return err
+	}
+
+	if request.Params.WaitForCompletion {
+		err = waitForCompletion(ctx, spinClient, stderr, request)
+		if err != nil {
+			return err
+		}
+	}
+
+	res, err := spinClient.GetPipelineExecutionRaw(ctx, request.Version.Ref)
+	if err != nil {
+		return err
+	}
+
+	var pipelineExecution spinnaker.PipelineExecution
+	err = json.Unmarshal(res, &pipelineExecution)
+	if err != nil {
+		return err
+	}
+	if !pipelineExecution.Status.Matches(request.Params.ExpectedStatuses) {
+		return fmt.Errorf("pipeline execution %s reached status %s, expected one of: %s", request.Version.Ref, pipelineExecution.Status, strings.Join(request.Params.ExpectedStatuses, ", "))
+	}
+
+	dest := args[0]
+
+	err = ioutil.WriteFile(filepath.Join(dest, "metadata.json"), res, 0644)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dest, "version"), []byte(request.Version.Ref), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = writeExecutionFiles(dest, pipelineExecution)
+	if err != nil {
+		return err
+	}
+
+	artifacts := pipelineExecution.Artifacts()
+	if artifacts == nil {
+		artifacts = []spinnaker.Artifact{}
+	}
+	err = writeJSON(filepath.Join(dest, "artifacts.json"), artifacts)
+	if err != nil {
+		return err
+	}
+	if request.Params.FetchArtifacts {
+		err = fetchArtifacts(ctx, spinClient, stderr, dest, artifacts)
+		if err != nil {
+			return err
+		}
+	}
+
+	resArr := []concourse.InResponseMetadata{
+		concourse.InResponseMetadata{
+			Name:  "Application Name",
+			Value: pipelineExecution.Application,
+		},
+		concourse.InResponseMetadata{
+			Name:  "Pipeline Name",
+			Value: pipelineExecution.Name,
+		},
+		concourse.InResponseMetadata{
+			Name:  "Status",
+			Value: string(pipelineExecution.Status),
+		},
+		concourse.InResponseMetadata{
+			Name:  "Start time",
+			Value: time.Unix(pipelineExecution.StartTime/1000, 0).Format(time.UnixDate),
+		},
+		concourse.InResponseMetadata{
+			Name:  "End time",
+			Value: time.Unix(pipelineExecution.EndTime/1000, 0).Format(time.UnixDate),
+		},
+	}
+
+	if url := spinClient.ExecutionURL(request.Version.Ref); url != "" {
+		err = ioutil.WriteFile(filepath.Join(dest, "url"), []byte(url), 0644)
+		if err != nil {
+			return err
+		}
+		resArr = append(resArr, concourse.InResponseMetadata{
+			Name:  "URL",
+			Value: url,
+		})
+	}
+
+	InResponse := concourse.InResponse{
+		Version:  request.Version,
+		Metadata: resArr,
+	}
+
+	return concourse.WriteResponse(stdout, InResponse)
+}
+
+// waitForCompletion polls the execution until it reaches a final state.
+func waitForCompletion(ctx context.Context, spinClient spinnaker.SpinClient, stderr io.Writer, request concourse.InRequest) error {
+	interval, err := spinnaker.ParseDurationDefault(request.Params.Interval, defaultPollingInterval)
+	if err != nil {
+		return err
+	}
+	timeout, err := spinnaker.ParseDurationDefault(request.Params.Timeout, defaultPollingTimeout)
+	if err != nil {
+		return err
+	}
+
+	concourse.Sayf(stderr, "Waiting for pipeline execution %s to complete, Poll Interval: %v, Timeout: %v\n", request.Version.Ref, interval, timeout)
+
+	poller := poll.NewPoller(spinClient, stderr, interval, timeout)
+	_, err = poller.Poll(ctx, request.Version.Ref, func(pipelineExecution spinnaker.PipelineExecution) (bool, error) {
+		return pipelineExecution.Status.IsTerminal(), nil
+	})
+	if err == poll.ErrTimeout {
+		return fmt.Errorf("timed out waiting for pipeline execution %s to complete", request.Version.Ref)
+	}
+	return err
+}
@@ -0,0 +1,340 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package in_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/in"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker/gatetest"
+)
+
+var _ = Describe("In", func() {
+	var (
+		spinnakerServer *ghttp.Server
+		pipeline        *gatetest.Pipeline
+		request         concourse.InRequest
+		dest            string
+		args            []string
+		stdout, stderr  *bytes.Buffer
+		runErr          error
+	)
+
+	BeforeEach(func() {
+		pipeline = gatetest.NewPipeline("bar", "foo")
+		spinnakerServer = ghttp.NewServer()
+		spinnakerServer.AppendHandlers(pipeline.ClientHandlers()...)
+		request = concourse.InRequest{
+			Source:  pipeline.Source(spinnakerServer.URL()),
+			Version: concourse.Version{Ref: "EX1"},
+		}
+
+		var err error
+		dest, err = ioutil.TempDir("", "in")
+		Expect(err).ToNot(HaveOccurred())
+		args = []string{dest}
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+	})
+
+	JustBeforeEach(func() {
+		marshalledRequest, err := json.Marshal(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		runErr = in.Run(context.Background(), bytes.NewBuffer(marshalledRequest), stdout, stderr, args)
+	})
+
+	AfterEach(func() {
+		spinnakerServer.Close()
+		os.RemoveAll(dest)
+	})
+
+	Context("when the execution exists", func() {
+		BeforeEach(func() {
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+						"id":          "EX1",
+						"name":        "foo",
+						"application": "bar",
+						"status":      "SUCCEEDED",
+						"trigger": map[string]interface{}{
+							"parameters": map[string]interface{}{
+								"build_id": "42",
+								"replicas": 3,
+							},
+							"artifacts": []map[string]string{
+								{"type": "gcs/object", "name": "gs://bucket/manifest.yml", "reference": "gs://bucket/manifest.yml", "artifactAccount": "gcs"},
+								{"type": "s3/object", "name": "s3://bucket/app.tgz", "version": "v1", "reference": "s3://bucket/app.tgz", "artifactAccount": "s3"},
+								{"type": "s3/object", "name": "s3://bucket/app.tgz", "version": "v2", "reference": "s3://bucket/app.tgz", "artifactAccount": "s3"},
+								{"type": "docker/image", "name": "registry/app", "version": "v1", "reference": "registry/app:v1"},
+							},
+						},
+						"stages": []map[string]interface{}{
+							{
+								"id": "S1", "refId": "1", "name": "Deploy (Manifest)", "type": "deployManifest", "status": "SUCCEEDED",
+								"startTime": 1000, "endTime": 2000,
+								"outputs": map[string]interface{}{
+									"deploy.server.groups": map[string]interface{}{"us-east-1": []string{"app-v001"}},
+									"manifestName":         "deployment app",
+								},
+							},
+							{"id": "S2", "refId": "1<1", "name": "waitForManifestStable", "type": "waitForManifestStable", "status": "SUCCEEDED", "parentStageId": "S1"},
+							{"id": "S3", "refId": "2", "name": "Wait", "type": "wait", "status": "SUCCEEDED", "outputs": map[string]interface{}{"waitTime": 5}},
+							{"id": "S4", "refId": "3", "name": "Wait", "type": "wait", "status": "SUCCEEDED", "outputs": map[string]interface{}{"waitTime": 10}},
+						},
+					}),
+				),
+			)
+		})
+
+		It("writes the execution into the destination and the version to stdout", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			Expect(filepath.Join(dest, "metadata.json")).To(BeAnExistingFile())
+			version, err := ioutil.ReadFile(filepath.Join(dest, "version"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(version)).To(Equal("EX1"))
+
+			var response concourse.InResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version).To(Equal(concourse.Version{Ref: "EX1"}))
+			Expect(response.Metadata).To(ContainElement(concourse.InResponseMetadata{Name: "Status", Value: "SUCCEEDED"}))
+		})
+
+		It("writes the status, trigger parameters, stages and stage outputs as files", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			readFile := func(path ...string) string {
+				contents, err := ioutil.ReadFile(filepath.Join(append([]string{dest}, path...)...))
+				Expect(err).ToNot(HaveOccurred())
+				return string(contents)
+			}
+			Expect(readFile("status")).To(Equal("SUCCEEDED"))
+			Expect(readFile("trigger", "parameters.json")).To(MatchJSON(`{"build_id":"42","replicas":3}`))
+			Expect(readFile("trigger", "parameters", "build_id")).To(Equal("42"))
+			Expect(readFile("trigger", "parameters", "replicas")).To(Equal("3"))
+			Expect(readFile("stages.json")).To(MatchJSON(`[
+				{"id":"S1","refId":"1","name":"Deploy (Manifest)","type":"deployManifest","status":"SUCCEEDED","startTime":1000,"endTime":2000,"outputsDir":"outputs/Deploy (Manifest)"},
+				{"id":"S2","refId":"1<1","name":"waitForManifestStable","type":"waitForManifestStable","status":"SUCCEEDED","startTime":0,"endTime":0,"parentStageId":"S1"},
+				{"id":"S3","refId":"2","name":"Wait","type":"wait","status":"SUCCEEDED","startTime":0,"endTime":0,"outputsDir":"outputs/Wait [2]"},
+				{"id":"S4","refId":"3","name":"Wait","type":"wait","status":"SUCCEEDED","startTime":0,"endTime":0,"outputsDir":"outputs/Wait [3]"}
+			]`))
+			Expect(readFile("outputs", "Deploy (Manifest)", "manifestName")).To(Equal("deployment app"))
+			Expect(readFile("outputs", "Deploy (Manifest)", "deploy.server.groups")).To(MatchJSON(`{"us-east-1":["app-v001"]}`))
+		})
+
+		It("keeps the outputs of stages that share a name apart", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			Expect(ioutil.ReadFile(filepath.Join(dest, "outputs", "Wait [2]", "waitTime"))).To(BeEquivalentTo("5"))
+			Expect(ioutil.ReadFile(filepath.Join(dest, "outputs", "Wait [3]", "waitTime"))).To(BeEquivalentTo("10"))
+			Expect(filepath.Join(dest, "outputs", "Wait")).ToNot(BeADirectory())
+		})
+
+		It("writes the artifacts of the execution in the spinnaker artifact format", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			artifacts, err := ioutil.ReadFile(filepath.Join(dest, "artifacts.json"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(artifacts).To(MatchJSON(`[
+				{"type":"gcs/object","name":"gs://bucket/manifest.yml","reference":"gs://bucket/manifest.yml","artifactAccount":"gcs"},
+				{"type":"s3/object","name":"s3://bucket/app.tgz","version":"v1","reference":"s3://bucket/app.tgz","artifactAccount":"s3"},
+				{"type":"s3/object","name":"s3://bucket/app.tgz","version":"v2","reference":"s3://bucket/app.tgz","artifactAccount":"s3"},
+				{"type":"docker/image","name":"registry/app","version":"v1","reference":"registry/app:v1"}
+			]`))
+			Expect(filepath.Join(dest, "artifacts")).ToNot(BeADirectory())
+			Expect(filepath.Join(dest, "artifact_files.json")).ToNot(BeAnExistingFile())
+		})
+
+		Context("when fetch_artifacts is set", func() {
+			BeforeEach(func() {
+				request.Params.FetchArtifacts = true
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/artifacts/fetch"),
+						ghttp.VerifyJSON(`{"type":"gcs/object","name":"gs://bucket/manifest.yml","reference":"gs://bucket/manifest.yml","artifactAccount":"gcs"}`),
+						ghttp.RespondWith(200, "kind: Deployment"),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"type":"s3/object","name":"s3://bucket/app.tgz","version":"v1","reference":"s3://bucket/app.tgz","artifactAccount":"s3"}`),
+						ghttp.RespondWith(200, "app v1"),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"type":"s3/object","name":"s3://bucket/app.tgz","version":"v2","reference":"s3://bucket/app.tgz","artifactAccount":"s3"}`),
+						ghttp.RespondWith(200, "app v2"),
+					),
+				)
+			})
+
+			It("downloads the contents of the artifacts", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				contents, err := ioutil.ReadFile(filepath.Join(dest, "artifacts", "gs:__bucket_manifest.yml"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(contents)).To(Equal("kind: Deployment"))
+			})
+
+			It("adds the version to the files of artifacts that share a name", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				Expect(ioutil.ReadFile(filepath.Join(dest, "artifacts", "s3:__bucket_app.tgz@v1"))).To(BeEquivalentTo("app v1"))
+				Expect(ioutil.ReadFile(filepath.Join(dest, "artifacts", "s3:__bucket_app.tgz@v2"))).To(BeEquivalentTo("app v2"))
+			})
+
+			It("lists the file of each artifact in artifact_files.json and keeps artifacts.json in the spinnaker artifact format", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				files, err := ioutil.ReadFile(filepath.Join(dest, "artifact_files.json"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(files).To(MatchJSON(`[
+					{"index":0,"name":"gs://bucket/manifest.yml","file":"artifacts/gs:__bucket_manifest.yml"},
+					{"index":1,"name":"s3://bucket/app.tgz","version":"v1","file":"artifacts/s3:__bucket_app.tgz@v1"},
+					{"index":2,"name":"s3://bucket/app.tgz","version":"v2","file":"artifacts/s3:__bucket_app.tgz@v2"}
+				]`))
+
+				artifacts, err := ioutil.ReadFile(filepath.Join(dest, "artifacts.json"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(artifacts).ToNot(ContainSubstring(`"file"`))
+			})
+
+			It("skips the artifacts that cannot be fetched", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(6))
+				Expect(filepath.Join(dest, "artifacts", "registry_app")).ToNot(BeAnExistingFile())
+				Expect(stderr.String()).To(ContainSubstring("Skipping artifact registry/app: docker/image artifacts cannot be fetched"))
+			})
+
+			Context("and spinnaker fails to fetch one", func() {
+				BeforeEach(func() {
+					spinnakerServer.SetHandler(3, ghttp.RespondWith(500, `{"message":"no account gcs"}`))
+				})
+
+				It("returns an error", func() {
+					Expect(runErr).To(HaveOccurred())
+					Expect(runErr.Error()).To(HavePrefix("unable to fetch artifact gs://bucket/manifest.yml: spinnaker api responded with status code: 500"))
+				})
+			})
+		})
+
+		It("does not link to deck without a spinnaker_ui_url", func() {
+			Expect(filepath.Join(dest, "url")).ToNot(BeAnExistingFile())
+		})
+
+		Context("when a spinnaker_ui_url is configured", func() {
+			BeforeEach(func() {
+				request.Source.SpinnakerUIURL = "https://deck.example.com"
+			})
+
+			It("links to the execution in deck", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				url, err := ioutil.ReadFile(filepath.Join(dest, "url"))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(string(url)).To(Equal("https://deck.example.com/#/applications/bar/executions/details/EX1"))
+
+				var response concourse.InResponse
+				Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+				Expect(response.Metadata).To(ContainElement(concourse.InResponseMetadata{Name: "URL", Value: string(url)}))
+			})
+		})
+	})
+
+	Context("when waiting for completion", func() {
+		BeforeEach(func() {
+			request.Params.WaitForCompletion = true
+			request.Params.Interval = "10ms"
+			spinnakerServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "RUNNING"}),
+				ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "TERMINAL"}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "TERMINAL"}),
+				),
+			)
+		})
+
+		It("polls until the execution is in a final state before fetching it", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(5))
+
+			status, err := ioutil.ReadFile(filepath.Join(dest, "status"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(status)).To(Equal("TERMINAL"))
+		})
+
+		Context("and the final status is not expected", func() {
+			BeforeEach(func() {
+				request.Params.ExpectedStatuses = []string{"SUCCEEDED", "STOPPED"}
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("pipeline execution EX1 reached status TERMINAL, expected one of: SUCCEEDED, STOPPED"))
+				Expect(filepath.Join(dest, "version")).ToNot(BeAnExistingFile())
+			})
+		})
+
+		Context("and the execution does not finish in time", func() {
+			BeforeEach(func() {
+				request.Params.Interval = "1h"
+				request.Params.Timeout = "10ms"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("timed out waiting for pipeline execution EX1 to complete"))
+			})
+		})
+
+		Context("and the interval is invalid", func() {
+			BeforeEach(func() {
+				request.Params.Interval = "soon"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError(ContainSubstring("invalid duration")))
+			})
+		})
+	})
+
+	Context("when the execution does not exist", func() {
+		BeforeEach(func() {
+			spinnakerServer.AppendHandlers(ghttp.RespondWith(404, nil))
+		})
+
+		It("returns ErrExecutionNotFound", func() {
+			Expect(errors.Is(runErr, spinnaker.ErrExecutionNotFound)).To(BeTrue())
+			Expect(filepath.Join(dest, "version")).ToNot(BeAnExistingFile())
+		})
+	})
+
+	Context("when no destination is given", func() {
+		BeforeEach(func() {
+			args = nil
+		})
+
+		It("returns an error without calling spinnaker", func() {
+			Expect(runErr).To(MatchError("destination path not specified"))
+			Expect(spinnakerServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+})
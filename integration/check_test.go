@@ -26,6 +26,8 @@ import (
 var _ = Describe("Check", func() {
 	var (
 		applicationName, pipelineName string
+		pipelineConfigID              string
+		pageSize                      int
 		responseMap                   []map[string]interface{}
 		input                         concourse.CheckRequest
 		marshalledInput               []byte
@@ -33,13 +35,14 @@ var _ = Describe("Check", func() {
 		statusCode                    int
 		pipelineExecutions            []map[string]interface{}
 		checkResponse                 []concourse.Version
-		allHandler                    http.HandlerFunc
+		allHandlers                   []http.HandlerFunc
 		inputRef                      string
 		checkSess                     *gexec.Session
 		statuses                      []string
 	)
 	pipelineName = "foo"
 	applicationName = "bar"
+	pipelineConfigID = "foo-config-id"
 	pipelineExecutions = []map[string]interface{}{
 		map[string]interface{}{
 			"id":        "EX1",
@@ -59,19 +62,10 @@ var _ = Describe("Check", func() {
 			"buildTime": 1543244690,
 			"status":    "TERMINAL",
 		},
-		map[string]interface{}{
-			"id":        "EX4",
-			"name":      "other-pipeline",
-			"buildTime": 1543244690,
-			"status":    "SUCCEEDED",
-		},
-		map[string]interface{}{
-			"id":        "EX5",
-			"name":      "other-pipeline",
-			"buildTime": 1543244690,
-			"status":    "SUCCEEDED",
-		},
 	}
+	BeforeEach(func() {
+		pageSize = 0
+	})
 	JustBeforeEach(func() {
 		spinnakerServer.AppendHandlers(
 			ghttp.CombineHandlers(
@@ -92,17 +86,19 @@ var _ = Describe("Check", func() {
 				ghttp.RespondWithJSONEncoded(
 					statusCode,
 					[]map[string]string{
-						{"name": pipelineName},
+						{"name": pipelineName, "id": pipelineConfigID},
+						{"name": "other-pipeline", "id": "other-config-id"},
 					},
 				)),
-			allHandler,
 		)
+		spinnakerServer.AppendHandlers(allHandlers...)
 		input = concourse.CheckRequest{
 			Source: concourse.Source{
 				SpinnakerAPI:         spinnakerServer.URL(),
 				SpinnakerApplication: applicationName,
 				SpinnakerPipeline:    pipelineName,
 				Statuses:             statuses,
+				CheckPageSize:        pageSize,
 				X509Cert:             serverCert,
 				X509Key:              serverKey,
 			},
@@ -121,21 +117,31 @@ var _ = Describe("Check", func() {
 	Context("when input version is not empty", func() {
 		BeforeEach(func() {
 			statusCode = 200
-			allHandler = ghttp.CombineHandlers(
-				ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
+			allHandlers = []http.HandlerFunc{ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds="+pipelineConfigID),
 				ghttp.RespondWithJSONEncoded(
 					statusCode,
 					pipelineExecutions,
 				),
-			)
+			)}
 		})
 		Context("when statuses are specified in the resource params", func() {
 			BeforeEach(func() {
 				inputRef = pipelineExecutions[0]["id"].(string)
 				statuses = []string{"SUCCEEDED"}
+				allHandlers = []http.HandlerFunc{ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds="+pipelineConfigID+"&statuses=SUCCEEDED"),
+					ghttp.RespondWithJSONEncoded(
+						statusCode,
+						[]map[string]interface{}{
+							pipelineExecutions[1],
+							pipelineExecutions[0],
+						},
+					),
+				)}
 			})
 
-			It("returns the versions that match the provided statuses", func() {
+			It("asks spinnaker for the versions that match the provided statuses", func() {
 				Expect(checkSess.ExitCode()).To(Equal(0))
 
 				err = json.Unmarshal(checkSess.Out.Contents(), &checkResponse)
@@ -176,20 +182,70 @@ var _ = Describe("Check", func() {
 					Expect(checkResponse[0].Ref).To(Equal(pipelineExecutions[2]["id"].(string)))
 				})
 			})
+			Context("when input version is older than the first page of executions", func() {
+				BeforeEach(func() {
+					pageSize = 2
+					allHandlers = []http.HandlerFunc{
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("GET", "/executions", "limit=2&pipelineConfigIds="+pipelineConfigID),
+							ghttp.RespondWithJSONEncoded(
+								statusCode,
+								[]map[string]interface{}{
+									pipelineExecutions[2],
+									pipelineExecutions[1],
+								},
+							),
+						),
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("GET", "/pipelines/"+pipelineExecutions[0]["id"].(string)),
+							ghttp.RespondWithJSONEncoded(statusCode, pipelineExecutions[0]),
+						),
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("GET", "/executions", "limit=4&pipelineConfigIds="+pipelineConfigID),
+							ghttp.RespondWithJSONEncoded(
+								statusCode,
+								[]map[string]interface{}{
+									pipelineExecutions[2],
+									pipelineExecutions[1],
+									pipelineExecutions[0],
+								},
+							),
+						),
+					}
+					inputRef = pipelineExecutions[0]["id"].(string)
+					statuses = []string{}
+				})
+				It("pages back until the input version is found and returns every version from there", func() {
+					Expect(checkSess.ExitCode()).To(Equal(0))
+					Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(5))
+
+					err = json.Unmarshal(checkSess.Out.Contents(), &checkResponse)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(len(checkResponse)).To(Equal(3))
+					Expect(checkResponse[0].Ref).To(Equal(pipelineExecutions[0]["id"].(string)))
+					Expect(checkResponse[1].Ref).To(Equal(pipelineExecutions[1]["id"].(string)))
+					Expect(checkResponse[2].Ref).To(Equal(pipelineExecutions[2]["id"].(string)))
+				})
+			})
 			Context("when input version doesn't exist anymore", func() {
 				BeforeEach(func() {
 					responseMap = []map[string]interface{}{
-						pipelineExecutions[1],
 						pipelineExecutions[2],
-						pipelineExecutions[3],
+						pipelineExecutions[1],
 					}
-					allHandler = ghttp.CombineHandlers(
-						ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
-						ghttp.RespondWithJSONEncoded(
-							statusCode,
-							responseMap,
+					allHandlers = []http.HandlerFunc{
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds="+pipelineConfigID),
+							ghttp.RespondWithJSONEncoded(
+								statusCode,
+								responseMap,
+							),
+						),
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("GET", "/pipelines/"+pipelineExecutions[0]["id"].(string)),
+							ghttp.RespondWith(404, ""),
 						),
-					)
+					}
 					inputRef = pipelineExecutions[0]["id"].(string)
 					statuses = []string{}
 				})
@@ -210,25 +266,33 @@ var _ = Describe("Check", func() {
 			pipelineName = "foo"
 			applicationName = "bar"
 			responseMap = []map[string]interface{}{
-				pipelineExecutions[0],
-				pipelineExecutions[1],
 				pipelineExecutions[2],
-				pipelineExecutions[3],
+				pipelineExecutions[1],
+				pipelineExecutions[0],
 			}
 			statuses = []string{}
 			statusCode = 200
-			allHandler = ghttp.CombineHandlers(
-				ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
+			allHandlers = []http.HandlerFunc{ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds="+pipelineConfigID),
 				ghttp.RespondWithJSONEncoded(
 					statusCode,
 					responseMap,
 				),
-			)
+			)}
 		})
 		Context("when statuses are specified", func() {
 			BeforeEach(func() {
 				statuses = []string{"SUCCEEDED"}
-
+				allHandlers = []http.HandlerFunc{ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds="+pipelineConfigID+"&statuses=SUCCEEDED"),
+					ghttp.RespondWithJSONEncoded(
+						statusCode,
+						[]map[string]interface{}{
+							pipelineExecutions[1],
+							pipelineExecutions[0],
+						},
+					),
+				)}
 			})
 			It("returns the only the latest version that mathces the specified statuses to stdout", func() {
 				Expect(checkSess.ExitCode()).To(Equal(0))
@@ -241,15 +305,19 @@ var _ = Describe("Check", func() {
 
 			Context("when pipeline executions does not have the status we are looking for", func() {
 				BeforeEach(func() {
-					statuses = []string{"FAILED"}
+					statuses = []string{"FAILED", "CANCELED"}
+					allHandlers = []http.HandlerFunc{ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds="+pipelineConfigID+"&statuses=FAILED%2CCANCELED"),
+						ghttp.RespondWithJSONEncoded(
+							statusCode,
+							[]map[string]interface{}{},
+						),
+					)}
 				})
 
-				It("returns no versions", func() {
+				It("returns an empty list of versions", func() {
 					Expect(checkSess.ExitCode()).To(Equal(0))
-
-					err = json.Unmarshal(checkSess.Out.Contents(), &checkResponse)
-					Expect(err).ToNot(HaveOccurred())
-					Expect(len(checkResponse)).To(Equal(0))
+					Expect(checkSess.Out.Contents()).To(MatchJSON("[]"))
 				})
 			})
 		})
@@ -258,28 +326,22 @@ var _ = Describe("Check", func() {
 				statuses = []string{}
 			})
 
-			Context("when pipeline executions does not have the status we are looking for", func() {
+			Context("when the pipeline has no executions", func() {
 				BeforeEach(func() {
 					statusCode = 200
 
-					allHandler = ghttp.CombineHandlers(
-						ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelines"), "limit=25"),
+					allHandlers = []http.HandlerFunc{ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/executions", "limit=25&pipelineConfigIds="+pipelineConfigID),
 						ghttp.RespondWithJSONEncoded(
 							statusCode,
-							[]map[string]interface{}{
-								pipelineExecutions[3],
-								pipelineExecutions[4],
-							},
+							[]map[string]interface{}{},
 						),
-					)
+					)}
 				})
 
-				It("returns no versions", func() {
+				It("returns an empty list of versions", func() {
 					Expect(checkSess.ExitCode()).To(Equal(0))
-
-					err = json.Unmarshal(checkSess.Out.Contents(), &checkResponse)
-					Expect(err).ToNot(HaveOccurred())
-					Expect(len(checkResponse)).To(Equal(0))
+					Expect(checkSess.Out.Contents()).To(MatchJSON("[]"))
 				})
 			})
 
@@ -206,6 +206,8 @@ var _ = Describe("In", func() {
 
 				Expect(inSess.Err).Should(gbytes.Say("error get step failed:"))
 				Expect(inSess.Err).Should(gbytes.Say("spinnaker api responded with status code: " + strconv.Itoa(statusCode)))
+				Expect(inSess.Err).Should(gbytes.Say("exception: org.springframework.web.method.annotation.MethodArgumentTypeMismatchException, message: something bad happend"))
+				Expect(inSess.Err).Should(gbytes.Say("hint: Spinnaker failed to handle the request"))
 			})
 		})
 
@@ -232,7 +234,9 @@ var _ = Describe("In", func() {
 				Expect(inSess.ExitCode()).To(Equal(1))
 
 				Expect(inSess.Err).Should(gbytes.Say("error get step failed: "))
-				Expect(inSess.Err).Should(gbytes.Say("pipeline execution ID not found \\(ID: " + pipelineID + "\\)\n"))
+				Expect(inSess.Err).Should(gbytes.Say("pipeline execution not found: spinnaker api responded with status code: 404"))
+				Expect(inSess.Err).Should(gbytes.Say("message: Pipeline not found \\(id: " + pipelineID + "\\)"))
+				Expect(inSess.Err).Should(gbytes.Say("hint: the pipeline execution may have been deleted from Spinnaker"))
 			})
 		})
 	})
@@ -14,8 +14,11 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"os/exec"
+	"path/filepath"
 	"strconv"
+	"strings"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -49,6 +52,7 @@ var _ = Describe("Out", func() {
 			X509Key:              serverKey,
 		}
 		pipelineExecutionID = "ABC123"
+		inputParams = concourse.OutParams{}
 
 		spinnakerServer.AppendHandlers(
 			ghttp.CombineHandlers(
@@ -201,6 +205,76 @@ var _ = Describe("Out", func() {
 			})
 		})
 
+		Context("when typed trigger params are defined inline and in a json file", func() {
+			BeforeEach(func() {
+				postBody := `{
+					"type": "concourse-resource",
+					"parameters": {
+						"replicas": 5,
+						"canary": true,
+						"regions": ["us-east-1", "${REGION}"],
+						"build": {"id": "${BUILD_ID}", "tags": ["${BUILD_ID}-tag"]},
+						"big": 12345678901234567890,
+						"big_inline": 12345678901234567891,
+						"untouched": "${NOT_EXPANDED}"
+					}
+				}`
+				httpPOSTSuccessHandler = ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", MatchRegexp(".*/pipelines/"+inputSource.SpinnakerApplication+"/"+pipelineName+".*")),
+					// MatchJSON compares numbers as float64, so the exact digits are checked in the body as well
+					func(w http.ResponseWriter, req *http.Request) {
+						body, err := ioutil.ReadAll(req.Body)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(body).To(MatchJSON(strings.Replace(strings.Replace(postBody, "${REGION}", "eu-west-1", 1), "${BUILD_ID}", "42", 2)))
+						Expect(string(body)).To(ContainSubstring(`"big":12345678901234567890`))
+						Expect(string(body)).To(ContainSubstring(`"big_inline":12345678901234567891`))
+					},
+					ghttp.RespondWithJSONEncoded(
+						202,
+						map[string]string{
+							"ref": "/pipelines/" + pipelineExecutionID,
+						},
+					),
+				)
+				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler)
+
+				dir, err := ioutil.TempDir("", "location_for_params")
+				Expect(err).ToNot(HaveOccurred())
+
+				fileParams := `{"replicas": 5, "big": 12345678901234567890, "untouched": "${NOT_EXPANDED}"}`
+				err = ioutil.WriteFile(dir+"/my-trigger-params.json", []byte(fileParams), 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				inputParams = concourse.OutParams{
+					TriggerParams: map[string]interface{}{
+						"replicas":   1,
+						"canary":     true,
+						"big_inline": json.Number("12345678901234567891"),
+						"regions":    []string{"us-east-1", "${REGION}"},
+						"build": map[string]interface{}{
+							"id":   "${BUILD_ID}",
+							"tags": []string{"${BUILD_ID}-tag"},
+						},
+					},
+					TriggerParamsJSONFilePath: dir + "/my-trigger-params.json",
+				}
+			})
+
+			It("expands nested strings and lets the file take precedence", func() {
+				cmd := exec.Command(outPath, "")
+				cmd.Env = []string{"REGION=eu-west-1", "BUILD_ID=42", "NOT_EXPANDED=oops"}
+				cmd.Stdin = bytes.NewBuffer(marshalledInput)
+				outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).ToNot(HaveOccurred())
+				<-outSess.Exited
+				Expect(outSess.ExitCode()).To(Equal(0))
+
+				err = json.Unmarshal(outSess.Out.Contents(), &outResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(outResponse.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Trigger parameter: build", Value: `{"id":"42","tags":["42-tag"]}`}))
+			})
+		})
+
 		Context("when trigger params are defined", func() {
 			BeforeEach(func() {
 				postBody := `{"type":"concourse-resource","parameters":{"foo":"bar", "foobar": "bazbar"}}`
@@ -217,7 +291,7 @@ var _ = Describe("Out", func() {
 				spinnakerServer.AppendHandlers(httpPOSTSuccessHandler)
 
 				inputParams = concourse.OutParams{
-					TriggerParams: map[string]string{
+					TriggerParams: map[string]interface{}{
 						"foo":    "bar",
 						"foobar": "$BAZ",
 					},
@@ -278,7 +352,7 @@ var _ = Describe("Out", func() {
 
 					Expect(outSess.Err).To(gbytes.Say("\\.\\.\n"))
 					Expect(outSess.Err).To(gbytes.Say("error put step failed: "))
-					Expect(outSess.Err).To(gbytes.Say("timed out waiting for configured status\\(es\\)"))
+					Expect(outSess.Err).To(gbytes.Say("timed out waiting for configured status\\(es\\), left pipeline execution " + pipelineExecutionID + " running"))
 				})
 			})
 
@@ -323,6 +397,35 @@ var _ = Describe("Out", func() {
 				})
 			})
 
+			Context("when spinnaker returns an unexpected pipeline execution payload", func() {
+				BeforeEach(func() {
+					spinnakerServer.AppendHandlers(
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("GET", MatchRegexp(".*/pipelines/"+pipelineExecutionID+".*")),
+							ghttp.RespondWithJSONEncoded(
+								200,
+								map[string]interface{}{
+									"id":     pipelineExecutionID,
+									"status": 42,
+								},
+							),
+						),
+					)
+				})
+
+				It("exits with non zero code and prints an error message instead of panicking", func() {
+					cmd := exec.Command(outPath, "")
+					cmd.Stdin = bytes.NewBuffer(marshalledInput)
+					outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+					Expect(err).ToNot(HaveOccurred())
+					<-outSess.Exited
+					Expect(outSess.ExitCode()).To(Equal(1))
+
+					Expect(outSess.Err).To(gbytes.Say("error put step failed:"))
+					Expect(outSess.Err).To(gbytes.Say("unable to parse pipeline execution " + pipelineExecutionID))
+				})
+			})
+
 			Context("when a status is specified, and reached", func() {
 				BeforeEach(func() {
 					spinnakerServer.AppendHandlers(
@@ -363,6 +466,210 @@ var _ = Describe("Out", func() {
 				})
 			})
 		})
+
+		Context("when a stage to wait for is defined", func() {
+			BeforeEach(func() {
+				inputSource.StatusCheckInterval = "200ms"
+				inputParams = concourse.OutParams{WaitForStage: "deploy-prod"}
+				spinnakerServer.AppendHandlers(
+					httpPOSTSuccessHandler,
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/pipelines/"+pipelineExecutionID),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"id":     pipelineExecutionID,
+							"status": "RUNNING",
+							"stages": []map[string]string{
+								{"id": "S1", "refId": "1", "name": "deploy-prod", "status": "RUNNING"},
+								{"id": "S2", "refId": "2", "name": "verify", "status": "NOT_STARTED"},
+							},
+						}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/pipelines/"+pipelineExecutionID),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"id":     pipelineExecutionID,
+							"status": "RUNNING",
+							"stages": []map[string]string{
+								{"id": "S1", "refId": "1", "name": "deploy-prod", "status": "SUCCEEDED"},
+								{"id": "S2", "refId": "2", "name": "verify", "status": "RUNNING"},
+							},
+						}),
+					),
+				)
+			})
+
+			It("returns the pipeline execution id once the stage succeeds", func() {
+				cmd := exec.Command(outPath, "")
+				cmd.Stdin = bytes.NewBuffer(marshalledInput)
+				outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+				Expect(err).ToNot(HaveOccurred())
+				<-outSess.Exited
+				Expect(spinnakerServer.ReceivedRequests()).Should(HaveLen(5))
+				Expect(outSess.ExitCode()).To(Equal(0))
+				Expect(outSess.Err).To(gbytes.Say(`\[deploy-prod\] RUNNING -> SUCCEEDED`))
+
+				err = json.Unmarshal(outSess.Out.Contents(), &outResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(outResponse.Version.Ref).To(Equal(pipelineExecutionID))
+			})
+		})
+	})
+
+	Context("when an action other than trigger is given", func() {
+		var sourcesDir string
+
+		BeforeEach(func() {
+			sourcesDir, err = ioutil.TempDir("", "sources")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(sourcesDir)
+		})
+
+		runOut := func() *gexec.Session {
+			cmd := exec.Command(outPath, sourcesDir)
+			cmd.Stdin = bytes.NewBuffer(marshalledInput)
+			outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
+			Expect(err).ToNot(HaveOccurred())
+			<-outSess.Exited
+			return outSess
+		}
+
+		Context("when cancelling the execution in an execution_id_file", func() {
+			BeforeEach(func() {
+				err = os.MkdirAll(filepath.Join(sourcesDir, "spinnaker"), 0755)
+				Expect(err).ToNot(HaveOccurred())
+				err = ioutil.WriteFile(filepath.Join(sourcesDir, "spinnaker", "version"), []byte(pipelineExecutionID), 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				inputParams = concourse.OutParams{
+					Action:          "cancel",
+					ExecutionIDFile: "spinnaker/version",
+					CancelReason:    "bad rollout",
+				}
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/pipelines/"+pipelineExecutionID+"/cancel", "reason=bad+rollout"),
+						ghttp.RespondWith(200, nil),
+					),
+				)
+			})
+
+			It("cancels the execution and returns its id as the version", func() {
+				outSess := runOut()
+				Expect(outSess.ExitCode()).To(Equal(0))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(3))
+				Expect(outSess.Err).To(gbytes.Say("Canceling pipeline execution: " + pipelineExecutionID))
+
+				err = json.Unmarshal(outSess.Out.Contents(), &outResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(outResponse.Version.Ref).To(Equal(pipelineExecutionID))
+			})
+		})
+
+		Context("when pausing without an execution_id_file", func() {
+			BeforeEach(func() {
+				inputParams = concourse.OutParams{Action: "pause"}
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/executions", "limit=1&pipelineConfigIds=&statuses=RUNNING"),
+						ghttp.RespondWithJSONEncoded(200, []map[string]string{
+							{"id": pipelineExecutionID, "status": "RUNNING"},
+						}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PUT", "/pipelines/"+pipelineExecutionID+"/pause"),
+						ghttp.RespondWith(200, nil),
+					),
+				)
+			})
+
+			It("pauses the latest running execution", func() {
+				outSess := runOut()
+				Expect(outSess.ExitCode()).To(Equal(0))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(4))
+
+				err = json.Unmarshal(outSess.Out.Contents(), &outResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(outResponse.Version.Ref).To(Equal(pipelineExecutionID))
+			})
+		})
+
+		Context("when resuming and no execution is paused", func() {
+			BeforeEach(func() {
+				inputParams = concourse.OutParams{Action: "resume"}
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/executions", "limit=1&pipelineConfigIds=&statuses=PAUSED"),
+						ghttp.RespondWithJSONEncoded(200, []map[string]string{}),
+					),
+				)
+			})
+
+			It("exits with non zero code and prints an error message", func() {
+				outSess := runOut()
+				Expect(outSess.ExitCode()).To(Equal(1))
+				Expect(outSess.Err).To(gbytes.Say("error put step failed: no paused execution of pipeline 'bar/foo' to resume"))
+			})
+		})
+
+		Context("when judging a manual judgment stage of the execution in an execution_id_file", func() {
+			BeforeEach(func() {
+				err = ioutil.WriteFile(filepath.Join(sourcesDir, "version"), []byte(pipelineExecutionID+"\n"), 0644)
+				Expect(err).ToNot(HaveOccurred())
+
+				inputParams = concourse.OutParams{
+					Action:          "judge",
+					ExecutionIDFile: "version",
+					JudgmentStatus:  "stop",
+					JudgmentStage:   "Approve prod",
+					JudgmentInput:   "smoke tests failed",
+				}
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/pipelines/"+pipelineExecutionID),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"id":     pipelineExecutionID,
+							"status": "RUNNING",
+							"stages": []map[string]string{
+								{"id": "STAGE1", "refId": "1", "type": "manualJudgment", "name": "Approve staging", "status": "RUNNING"},
+								{"id": "STAGE2", "refId": "2", "type": "manualJudgment", "name": "Approve prod", "status": "RUNNING"},
+							},
+						}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PATCH", "/pipelines/"+pipelineExecutionID+"/stages/STAGE2"),
+						ghttp.VerifyJSON(`{"judgmentStatus":"stop","judgmentInput":"smoke tests failed"}`),
+						ghttp.RespondWith(200, nil),
+					),
+				)
+			})
+
+			It("sends the judgment to the named stage", func() {
+				outSess := runOut()
+				Expect(outSess.ExitCode()).To(Equal(0))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(4))
+				Expect(outSess.Err).To(gbytes.Say("Judging stage 'Approve prod' of pipeline execution " + pipelineExecutionID + ": stop"))
+
+				err = json.Unmarshal(outSess.Out.Contents(), &outResponse)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(outResponse.Version.Ref).To(Equal(pipelineExecutionID))
+			})
+		})
+
+		Context("when the action is not supported", func() {
+			BeforeEach(func() {
+				inputParams = concourse.OutParams{Action: "restart"}
+			})
+
+			It("exits with non zero code without calling spinnaker", func() {
+				outSess := runOut()
+				Expect(outSess.ExitCode()).To(Equal(1))
+				Expect(outSess.Err).To(gbytes.Say("unsupported action: restart"))
+				Expect(spinnakerServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
 	})
 
 	Context("when Spinnaker responds with status code 4xx on a POST for a pipeline execution", func() {
@@ -384,7 +691,7 @@ var _ = Describe("Out", func() {
 
 		})
 
-		It("prints the status code, response body and exits with exit code 1", func() {
+		It("prints the status code, the error message from spinnaker and exits with exit code 1", func() {
 			cmd := exec.Command(outPath, "")
 			cmd.Stdin = bytes.NewBuffer(marshalledInput)
 			outSess, err := gexec.Start(cmd, GinkgoWriter, GinkgoWriter)
@@ -394,9 +701,7 @@ var _ = Describe("Out", func() {
 
 			Expect(outSess.Err).To(gbytes.Say("error put step failed:"))
 			Expect(outSess.Err).To(gbytes.Say("spinnaker api responded with status code: " + strconv.Itoa(statusCode)))
-			responseString, err := json.Marshal(responseMap)
-			Expect(err).ToNot(HaveOccurred())
-			Expect(outSess.Err).To(gbytes.Say("body: " + string(responseString)))
+			Expect(outSess.Err).To(gbytes.Say("message: " + responseMap["message"]))
 		})
 	})
 })
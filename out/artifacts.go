@@ -0,0 +1,283 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+const (
+	artifactTypeDockerImage    = "docker/image"
+	artifactTypeEmbeddedBase64 = "embedded/base64"
+
+	defaultEmbedArtifactsMaxSize      = 1 << 20
+	defaultEmbedArtifactsMaxTotalSize = 4 << 20
+)
+
+// triggerArtifacts returns the contents of artifacts_json_file followed by
+// the artifacts built from the artifacts param, or nil without either.
+func (c *command) triggerArtifacts() (interface{}, error) {
+	var fileArtifacts interface{}
+	if len(c.request.Params.Artifacts) > 0 {
+		localPath := filepath.Join(c.sourcesDir, c.request.Params.Artifacts)
+		contents, err := ioutil.ReadFile(localPath)
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(contents, &fileArtifacts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(c.request.Params.ArtifactList) == 0 && len(c.request.Params.EmbedArtifacts) == 0 {
+		return fileArtifacts, nil
+	}
+
+	var artifacts []interface{}
+	if fileArtifacts != nil {
+		list, ok := fileArtifacts.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("artifacts_json_file %s must contain an array of artifacts to be combined with artifacts", c.request.Params.Artifacts)
+		}
+		artifacts = append(artifacts, list...)
+	}
+	for i, param := range c.request.Params.ArtifactList {
+		artifact, err := c.buildArtifact(param)
+		if err != nil {
+			label := fmt.Sprintf("artifacts[%d]", i)
+			if param.Type != "" {
+				label += fmt.Sprintf(" (%s)", param.Type)
+			}
+			return nil, fmt.Errorf("%s: %s", label, err)
+		}
+		artifacts = append(artifacts, artifact)
+	}
+	embedded, err := c.embedArtifacts()
+	if err != nil {
+		return nil, err
+	}
+	for _, artifact := range embedded {
+		artifacts = append(artifacts, artifact)
+	}
+	return artifacts, nil
+}
+
+// embedArtifacts builds an embedded/base64 artifact for each file matched by
+// the embed_artifacts param. A path names its artifact after its key and a
+// glob names each match after its key and the base name of the file.
+func (c *command) embedArtifacts() ([]spinnaker.Artifact, error) {
+	var names []string
+	for name := range c.request.Params.EmbedArtifacts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var artifacts []spinnaker.Artifact
+	for _, name := range names {
+		pattern := c.request.Params.EmbedArtifacts[name]
+		matches, err := filepath.Glob(filepath.Join(c.sourcesDir, pattern))
+		if err != nil {
+			return nil, fmt.Errorf("embed_artifacts %s: %s", name, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("embed_artifacts %s: no files match %s", name, pattern)
+		}
+		isGlob := strings.ContainsAny(pattern, "*?[")
+		for _, match := range matches {
+			artifactName := name
+			if isGlob {
+				artifactName = name + "/" + filepath.Base(match)
+			}
+			reference, err := c.embedFile(match)
+			if err != nil {
+				return nil, fmt.Errorf("embed_artifacts %s: %s", name, err)
+			}
+			artifacts = append(artifacts, spinnaker.Artifact{
+				Type:      artifactTypeEmbeddedBase64,
+				Name:      artifactName,
+				Reference: reference,
+			})
+		}
+	}
+	return artifacts, nil
+}
+
+// embedFile base64 encodes the contents of a file, refusing files larger than
+// embed_artifacts_max_size, or that bring the encoded size of every file
+// embedded so far over embed_artifacts_max_total_size, as gate rejects
+// requests with large bodies.
+func (c *command) embedFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("%s is a directory", path)
+	}
+	maxSize := c.request.Params.EmbedArtifactsMaxSize
+	if maxSize <= 0 {
+		maxSize = defaultEmbedArtifactsMaxSize
+	}
+	if info.Size() > maxSize {
+		return "", fmt.Errorf("%s is %d bytes, larger than the %d bytes allowed by embed_artifacts_max_size", path, info.Size(), maxSize)
+	}
+	maxTotal := c.request.Params.EmbedArtifactsMaxTotalSize
+	if maxTotal <= 0 {
+		maxTotal = defaultEmbedArtifactsMaxTotalSize
+	}
+	total := c.embeddedSize + int64(base64.StdEncoding.EncodedLen(int(info.Size())))
+	if total > maxTotal {
+		return "", fmt.Errorf("%s brings the embedded artifacts to %d bytes once base64 encoded, more than the %d bytes allowed by embed_artifacts_max_total_size", path, total, maxTotal)
+	}
+	c.embeddedSize = total
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(contents), nil
+}
+
+// buildArtifact assembles a spinnaker artifact from the values of an entry of
+// the artifacts param and the files it refers to.
+func (c *command) buildArtifact(param concourse.Artifact) (spinnaker.Artifact, error) {
+	if param.Type == "" {
+		return spinnaker.Artifact{}, fmt.Errorf("type is required")
+	}
+
+	artifact := spinnaker.Artifact{
+		Type:            param.Type,
+		Location:        param.Location,
+		ArtifactAccount: param.ArtifactAccount,
+	}
+	var err error
+	artifact.Name, err = c.valueOrFile(param.Name, param.NameFile)
+	if err != nil {
+		return artifact, err
+	}
+	artifact.Version, err = c.valueOrFile(param.Version, param.VersionFile)
+	if err != nil {
+		return artifact, err
+	}
+	artifact.Reference, err = c.valueOrFile(param.Reference, param.ReferenceFile)
+	if err != nil {
+		return artifact, err
+	}
+
+	switch param.Type {
+	case artifactTypeDockerImage:
+		if param.From != "" {
+			err = c.readDockerImage(param.From, &artifact)
+			if err != nil {
+				return artifact, err
+			}
+		}
+		if artifact.Name == "" {
+			artifact.Name = dockerImageName(artifact.Reference)
+		}
+	case artifactTypeEmbeddedBase64:
+		if param.File != "" {
+			artifact.Reference, err = c.embedFile(filepath.Join(c.sourcesDir, param.File))
+			if err != nil {
+				return artifact, err
+			}
+			if artifact.Name == "" {
+				artifact.Name = filepath.Base(param.File)
+			}
+		}
+		if artifact.Reference == "" {
+			return artifact, fmt.Errorf("file or reference is required")
+		}
+	}
+
+	if artifact.Reference == "" {
+		return artifact, fmt.Errorf("reference or reference_file is required")
+	}
+	if artifact.Name == "" {
+		artifact.Name = artifact.Reference
+	}
+	return artifact, nil
+}
+
+// readDockerImage fills in an image from the repository and the digest or
+// tag files written by the docker image and registry image resources.
+func (c *command) readDockerImage(dir string, artifact *spinnaker.Artifact) error {
+	repository, err := c.valueOrFile("", filepath.Join(dir, "repository"))
+	if err != nil {
+		return err
+	}
+	digest, err := c.optionalFile(filepath.Join(dir, "digest"))
+	if err != nil {
+		return err
+	}
+	tag, err := c.optionalFile(filepath.Join(dir, "tag"))
+	if err != nil {
+		return err
+	}
+
+	version, reference := tag, repository+":"+tag
+	switch {
+	case digest != "":
+		version, reference = digest, repository+"@"+digest
+	case tag == "":
+		reference = repository
+	}
+	if artifact.Name == "" {
+		artifact.Name = repository
+	}
+	if artifact.Version == "" {
+		artifact.Version = version
+	}
+	if artifact.Reference == "" {
+		artifact.Reference = reference
+	}
+	return nil
+}
+
+// valueOrFile is value, or else the trimmed contents of the file in the
+// sources directory.
+func (c *command) valueOrFile(value, file string) (string, error) {
+	if value != "" || file == "" {
+		return value, nil
+	}
+	contents, err := ioutil.ReadFile(filepath.Join(c.sourcesDir, file))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(contents)), nil
+}
+
+func (c *command) optionalFile(file string) (string, error) {
+	value, err := c.valueOrFile("", file)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	return value, err
+}
+
+// dockerImageName strips the tag or digest from an image reference.
+func dockerImageName(reference string) string {
+	if i := strings.Index(reference, "@"); i >= 0 {
+		reference = reference[:i]
+	}
+	if i := strings.LastIndex(reference, ":"); i > strings.LastIndex(reference, "/") {
+		reference = reference[:i]
+	}
+	return reference
+}
@@ -0,0 +1,416 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/poll"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+const defaultPollingInterval = "30s"
+const defaultPollingTimeout = "31s"
+const defaultCancelReason = "timed out waiting for configured status(es) in concourse"
+const defaultActionCancelReason = "canceled from concourse"
+
+const (
+	actionTrigger = "trigger"
+	actionCancel  = "cancel"
+	actionPause   = "pause"
+	actionResume  = "resume"
+	actionJudge   = "judge"
+)
+
+const (
+	onTimeoutCancel = "cancel"
+	onTimeoutPause  = "pause"
+	onTimeoutLeave  = "leave"
+)
+
+type command struct {
+	spinClient spinnaker.SpinClient
+	request    concourse.OutRequest
+	sourcesDir string
+	stderr     io.Writer
+
+	// base64 encoded bytes of the files embedded into artifacts so far
+	embeddedSize int64
+
+	// what was sent and last seen, for the metadata of the response
+	triggerParams     map[string]interface{}
+	pipelineExecution spinnaker.PipelineExecution
+}
+
+// Run triggers the configured pipeline with the params of the request,
+// reading any files they refer to from the sources directory given as the
+// first argument. With an action other than trigger it cancels, pauses,
+// resumes or judges a manual judgment stage of an existing execution of the
+// pipeline instead.
+func Run(ctx context.Context, stdin io.Reader, stdout, stderr io.Writer, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("not enough arguments supplied, usage: out <sources directory>")
+	}
+
+	var request concourse.OutRequest
+	err := concourse.ReadRequest(stdin, &request)
+	if err != nil {
+		return err
+	}
+
+	switch request.Params.OnTimeout {
+	case "", onTimeoutCancel, onTimeoutPause, onTimeoutLeave:
+	default:
+		return fmt.Errorf("unsupported on_timeout: %s, expected one of: cancel, pause, leave", request.Params.OnTimeout)
+	}
+	switch request.Params.TriggerParamsFormat {
+	case "", formatJSON, formatYAML, formatDotenv:
+	default:
+		return fmt.Errorf("unsupported trigger_params_format: %s, expected one of: json, yaml, dotenv", request.Params.TriggerParamsFormat)
+	}
+	switch request.Params.Action {
+	case "", actionTrigger, actionCancel, actionPause, actionResume:
+	case actionJudge:
+		switch request.Params.JudgmentStatus {
+		case spinnaker.JudgmentContinue, spinnaker.JudgmentStop:
+		default:
+			return fmt.Errorf("action judge requires a judgment_status of continue or stop, got: %q", request.Params.JudgmentStatus)
+		}
+	default:
+		return fmt.Errorf("unsupported action: %s, expected one of: trigger, cancel, pause, resume, judge", request.Params.Action)
+	}
+
+	spinClient, err := spinnaker.NewClient(ctx, request.Source)
+	if err != nil {
+		return err
+	}
+
+	cmd := command{
+		spinClient: spinClient,
+		request:    request,
+		sourcesDir: args[0],
+		stderr:     stderr,
+	}
+
+	if request.Params.Action != "" && request.Params.Action != actionTrigger {
+		pipelineExecutionID, err := cmd.updatePipelineExecution(ctx)
+		if err != nil {
+			return err
+		}
+		return cmd.writeResponse(stdout, pipelineExecutionID)
+	}
+
+	pipelineExecutionID, err := cmd.invokePipeline(ctx)
+	if err != nil {
+		return err
+	}
+	if len(request.Source.Statuses) > 0 || request.Params.WaitForStage != "" {
+		err = cmd.pollSpinnakerForStatus(ctx, pipelineExecutionID)
+		if err != nil {
+			return err
+		}
+	}
+	concourse.Sayf(stderr, "Pipeline executed successfully")
+	return cmd.writeResponse(stdout, pipelineExecutionID)
+}
+
+func (c *command) invokePipeline(ctx context.Context) (string, error) {
+	TriggerParamsMap := map[string]interface{}{"type": "concourse-resource"}
+
+	triggerParams, err := c.triggerParameters()
+	if err != nil {
+		return "", err
+	}
+	triggerParams, err = c.checkTriggerParameters(triggerParams)
+	if err != nil {
+		return "", err
+	}
+	c.triggerParams = triggerParams
+	if len(triggerParams) > 0 {
+		TriggerParamsMap["parameters"] = triggerParams
+	}
+	artifacts, err := c.triggerArtifacts()
+	if err != nil {
+		return "", err
+	}
+	if artifacts != nil {
+		TriggerParamsMap["artifacts"] = artifacts
+	}
+	postBody, err := json.Marshal(TriggerParamsMap)
+	if err != nil {
+		return "", err
+	}
+
+	concourse.Sayf(c.stderr, "Executing pipeline: '%s/%s'\n", c.request.Source.SpinnakerApplication, c.request.Source.SpinnakerPipeline)
+
+	pipelineExecution, err := c.spinClient.InvokePipelineExecution(ctx, postBody)
+	if err != nil {
+		return "", err
+	}
+	return pipelineExecution.ID, nil
+}
+
+func (c *command) pollSpinnakerForStatus(ctx context.Context, pipelineExecutionID string) error {
+
+	interval, err := spinnaker.ParseDurationDefault(c.request.Source.StatusCheckInterval, defaultPollingInterval)
+	if err != nil {
+		return err
+	}
+	timeout, err := spinnaker.ParseDurationDefault(c.request.Source.StatusCheckTimeout, defaultPollingTimeout)
+	if err != nil {
+		return err
+	}
+
+	concourse.Sayf(c.stderr, "Poll Interval: %v, Timeout: %v\n", interval, timeout)
+
+	poller := poll.NewPoller(c.spinClient, c.stderr, interval, timeout)
+	c.pipelineExecution, err = poller.Poll(ctx, pipelineExecutionID, c.checkStatus)
+	if err == poll.ErrTimeout {
+		return c.handleTimeout(ctx, pipelineExecutionID)
+	}
+	return err
+}
+
+// handleTimeout applies the on_timeout param to an execution that did not
+// reach the configured statuses in time.
+func (c *command) handleTimeout(ctx context.Context, pipelineExecutionID string) error {
+	timeoutErr := fmt.Errorf("timed out waiting for configured status(es)")
+
+	switch c.request.Params.OnTimeout {
+	case onTimeoutCancel:
+		reason := c.request.Params.CancelReason
+		if reason == "" {
+			reason = defaultCancelReason
+		}
+		concourse.Sayf(c.stderr, "Canceling pipeline execution: %s\n", pipelineExecutionID)
+		err := c.spinClient.CancelPipelineExecution(ctx, pipelineExecutionID, reason)
+		if err != nil {
+			return fmt.Errorf("%s, failed to cancel pipeline execution %s: %s", timeoutErr, pipelineExecutionID, err)
+		}
+		return fmt.Errorf("%s, canceled pipeline execution %s", timeoutErr, pipelineExecutionID)
+	case onTimeoutPause:
+		concourse.Sayf(c.stderr, "Pausing pipeline execution: %s\n", pipelineExecutionID)
+		err := c.spinClient.PausePipelineExecution(ctx, pipelineExecutionID)
+		if err != nil {
+			return fmt.Errorf("%s, failed to pause pipeline execution %s: %s", timeoutErr, pipelineExecutionID, err)
+		}
+		return fmt.Errorf("%s, paused pipeline execution %s", timeoutErr, pipelineExecutionID)
+	default:
+		return fmt.Errorf("%s, left pipeline execution %s running", timeoutErr, pipelineExecutionID)
+	}
+}
+
+// checkStatus is the poll.Check for the configured statuses, or for
+// wait_for_stage when it is set.
+func (c *command) checkStatus(pipelineExecution spinnaker.PipelineExecution) (bool, error) {
+	if c.request.Params.WaitForStage != "" {
+		return c.checkStageStatus(pipelineExecution)
+	}
+
+	status := pipelineExecution.Status
+	if status.Matches(c.request.Source.Statuses) {
+		return true, nil
+	}
+	if status.IsTerminal() {
+		return false, fmt.Errorf("Pipeline execution reached a final state: %s", status)
+	}
+	return false, nil
+}
+
+// updatePipelineExecution applies the cancel, pause, resume or judge action to
+// the execution it targets and returns its ID.
+func (c *command) updatePipelineExecution(ctx context.Context) (string, error) {
+	pipelineExecutionID, err := c.targetPipelineExecutionID(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	switch c.request.Params.Action {
+	case actionCancel:
+		reason := c.request.Params.CancelReason
+		if reason == "" {
+			reason = defaultActionCancelReason
+		}
+		concourse.Sayf(c.stderr, "Canceling pipeline execution: %s\n", pipelineExecutionID)
+		err = c.spinClient.CancelPipelineExecution(ctx, pipelineExecutionID, reason)
+	case actionPause:
+		concourse.Sayf(c.stderr, "Pausing pipeline execution: %s\n", pipelineExecutionID)
+		err = c.spinClient.PausePipelineExecution(ctx, pipelineExecutionID)
+	case actionResume:
+		concourse.Sayf(c.stderr, "Resuming pipeline execution: %s\n", pipelineExecutionID)
+		err = c.spinClient.ResumePipelineExecution(ctx, pipelineExecutionID)
+	case actionJudge:
+		err = c.judgeStage(ctx, pipelineExecutionID)
+	}
+	if err != nil {
+		return "", err
+	}
+	return pipelineExecutionID, nil
+}
+
+// judgeStage sends the judgment to the manual judgment stage of the execution
+// waiting for one, picked by judgment_stage when there are several.
+func (c *command) judgeStage(ctx context.Context, pipelineExecutionID string) error {
+	pipelineExecution, err := c.spinClient.GetPipelineExecution(ctx, pipelineExecutionID)
+	if err != nil {
+		return err
+	}
+
+	var stages []spinnaker.Stage
+	for _, stage := range pipelineExecution.WaitingManualJudgments() {
+		judgmentStage := c.request.Params.JudgmentStage
+		if judgmentStage == "" || judgmentStage == stage.Name || judgmentStage == stage.RefID {
+			stages = append(stages, stage)
+		}
+	}
+	switch {
+	case len(stages) == 0 && c.request.Params.JudgmentStage != "":
+		return fmt.Errorf("no manual judgment stage '%s' is waiting for a judgment in pipeline execution %s", c.request.Params.JudgmentStage, pipelineExecutionID)
+	case len(stages) == 0:
+		return fmt.Errorf("no manual judgment stage is waiting for a judgment in pipeline execution %s", pipelineExecutionID)
+	case len(stages) > 1:
+		return fmt.Errorf("%d manual judgment stages are waiting for a judgment in pipeline execution %s, set judgment_stage to pick one", len(stages), pipelineExecutionID)
+	}
+
+	concourse.Sayf(c.stderr, "Judging stage '%s' of pipeline execution %s: %s\n", stages[0].Name, pipelineExecutionID, c.request.Params.JudgmentStatus)
+	return c.spinClient.JudgeStage(ctx, pipelineExecutionID, stages[0].ID, spinnaker.Judgment{
+		JudgmentStatus: c.request.Params.JudgmentStatus,
+		JudgmentInput:  c.request.Params.JudgmentInput,
+	})
+}
+
+// targetPipelineExecutionID reads the execution ID from execution_id_file,
+// falling back to the latest execution of the pipeline the action applies to:
+// a paused one to resume, otherwise a running one.
+func (c *command) targetPipelineExecutionID(ctx context.Context) (string, error) {
+	if c.request.Params.ExecutionIDFile != "" {
+		localPath := filepath.Join(c.sourcesDir, c.request.Params.ExecutionIDFile)
+		contents, err := ioutil.ReadFile(localPath)
+		if err != nil {
+			return "", err
+		}
+		pipelineExecutionID := strings.TrimSpace(string(contents))
+		if pipelineExecutionID == "" {
+			return "", fmt.Errorf("execution_id_file %s is empty", c.request.Params.ExecutionIDFile)
+		}
+		return pipelineExecutionID, nil
+	}
+
+	status := spinnaker.StatusRunning
+	if c.request.Params.Action == actionResume {
+		status = spinnaker.StatusPaused
+	}
+	pipelineExecutions, err := c.spinClient.GetPipelineExecutions(ctx, spinnaker.ExecutionQuery{
+		Statuses: []string{string(status)},
+		Limit:    1,
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(pipelineExecutions) == 0 {
+		return "", fmt.Errorf("no %s execution of pipeline '%s/%s' to %s", strings.ToLower(string(status)), c.request.Source.SpinnakerApplication, c.request.Source.SpinnakerPipeline, c.request.Params.Action)
+	}
+	return pipelineExecutions[0].ID, nil
+}
+
+// checkStageStatus is the poll.Check for wait_for_stage: the status of the
+// execution itself only matters once it has finished without the stage
+// reaching a final state.
+func (c *command) checkStageStatus(pipelineExecution spinnaker.PipelineExecution) (bool, error) {
+	stage, found := findStage(pipelineExecution, c.request.Params.WaitForStage)
+	if !found {
+		if len(pipelineExecution.Stages) > 0 || pipelineExecution.Status.IsTerminal() {
+			return false, fmt.Errorf("stage '%s' not found in pipeline execution %s", c.request.Params.WaitForStage, pipelineExecution.ID)
+		}
+		return false, nil
+	}
+
+	statuses := c.request.Params.WaitForStageStatuses
+	if len(statuses) == 0 {
+		statuses = []string{string(spinnaker.StatusSucceeded)}
+	}
+	if stage.Status.Matches(statuses) {
+		return true, nil
+	}
+	if stage.Status.IsTerminal() {
+		return false, fmt.Errorf("Stage '%s' reached a final state: %s", stage.Name, stage.Status)
+	}
+	if pipelineExecution.Status.IsTerminal() {
+		return false, fmt.Errorf("Pipeline execution reached a final state: %s before stage '%s' did", pipelineExecution.Status, stage.Name)
+	}
+	return false, nil
+}
+
+// findStage finds a top level stage of the execution by name or refId.
+func findStage(pipelineExecution spinnaker.PipelineExecution, nameOrRefID string) (spinnaker.Stage, bool) {
+	for _, stage := range pipelineExecution.Stages {
+		if stage.ParentStageID != "" {
+			continue
+		}
+		if stage.Name == nameOrRefID || stage.RefID == nameOrRefID {
+			return stage, true
+		}
+	}
+	return spinnaker.Stage{}, false
+}
+
+func (c *command) writeResponse(stdout io.Writer, pipelineExecutionID string) error {
+	output := concourse.OutResponse{}
+	output.Version = concourse.Version{
+		Ref: pipelineExecutionID,
+	}
+	output.Metadata = c.metadata(pipelineExecutionID)
+
+	return concourse.WriteResponse(stdout, output)
+}
+
+// metadata describes what the put step did for the build page. Status and
+// duration are only known when put waited for the execution.
+func (c *command) metadata(pipelineExecutionID string) []concourse.MetadataPair {
+	metadata := []concourse.MetadataPair{
+		{Name: "Application Name", Value: c.request.Source.SpinnakerApplication},
+		{Name: "Pipeline Name", Value: c.request.Source.SpinnakerPipeline},
+		{Name: "Execution ID", Value: pipelineExecutionID},
+	}
+	if action := c.request.Params.Action; action != "" && action != actionTrigger {
+		metadata = append(metadata, concourse.MetadataPair{Name: "Action", Value: action})
+	}
+	if status := c.pipelineExecution.Status; status != "" {
+		metadata = append(metadata, concourse.MetadataPair{Name: "Status", Value: string(status)})
+	}
+	if duration := c.pipelineExecution.Duration(); duration > 0 {
+		metadata = append(metadata, concourse.MetadataPair{Name: "Duration", Value: duration.Round(time.Second).String()})
+	}
+	if url := c.spinClient.ExecutionURL(pipelineExecutionID); url != "" {
+		metadata = append(metadata, concourse.MetadataPair{Name: "URL", Value: url})
+	}
+
+	keys := make([]string, 0, len(c.triggerParams))
+	for key := range c.triggerParams {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		metadata = append(metadata, concourse.MetadataPair{
+			Name:  "Trigger parameter: " + key,
+			Value: metadataValue(redact(key, c.triggerParams[key])),
+		})
+	}
+	return metadata
+}
@@ -0,0 +1,890 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/out"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker/gatetest"
+)
+
+var _ = Describe("Out", func() {
+	var (
+		spinnakerServer *ghttp.Server
+		pipeline        *gatetest.Pipeline
+		request         concourse.OutRequest
+		ctx             context.Context
+		args            []string
+		stdout, stderr  *bytes.Buffer
+		runErr          error
+	)
+
+	BeforeEach(func() {
+		pipeline = gatetest.NewPipeline("bar", "foo")
+		spinnakerServer = ghttp.NewServer()
+		spinnakerServer.AppendHandlers(pipeline.ClientHandlers()...)
+		request = concourse.OutRequest{
+			Source: pipeline.Source(spinnakerServer.URL()),
+		}
+		ctx = context.Background()
+		args = []string{"sources"}
+		stdout = &bytes.Buffer{}
+		stderr = &bytes.Buffer{}
+	})
+
+	JustBeforeEach(func() {
+		marshalledRequest, err := json.Marshal(request)
+		Expect(err).ToNot(HaveOccurred())
+
+		runErr = out.Run(ctx, bytes.NewBuffer(marshalledRequest), stdout, stderr, args)
+	})
+
+	AfterEach(func() {
+		spinnakerServer.Close()
+	})
+
+	Context("when the pipeline is triggered", func() {
+		BeforeEach(func() {
+			request.Params.TriggerParams = map[string]interface{}{"foo": "bar"}
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+					ghttp.VerifyJSON(`{"type":"concourse-resource","parameters":{"foo":"bar"}}`),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+				),
+			)
+		})
+
+		It("writes the execution id as the version", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+
+			var response concourse.OutResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version).To(Equal(concourse.Version{Ref: "ABC123"}))
+			Expect(stderr.String()).To(ContainSubstring("Executing pipeline: 'bar/foo'"))
+		})
+
+		Context("with secrets in the trigger params and a spinnaker_ui_url", func() {
+			BeforeEach(func() {
+				request.Params.TriggerParams["db_password"] = "hunter2"
+				request.Params.TriggerParams["API_TOKEN"] = "abc"
+				request.Params.TriggerParams["db"] = map[string]interface{}{"host": "db.local", "port": 5432, "password": "hunter2"}
+				request.Source.SpinnakerUIURL = "https://deck.example.com"
+				spinnakerServer.SetHandler(2, ghttp.CombineHandlers(
+					ghttp.VerifyJSON(`{"type":"concourse-resource","parameters":{"foo":"bar","db_password":"hunter2","API_TOKEN":"abc","db":{"host":"db.local","port":5432,"password":"hunter2"}}}`),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+				))
+			})
+
+			It("describes the triggered execution in the metadata, redacting the secrets", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+
+				var response concourse.OutResponse
+				Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+				Expect(response.Metadata).To(Equal([]concourse.MetadataPair{
+					{Name: "Application Name", Value: "bar"},
+					{Name: "Pipeline Name", Value: "foo"},
+					{Name: "Execution ID", Value: "ABC123"},
+					{Name: "URL", Value: "https://deck.example.com/#/applications/bar/executions/details/ABC123"},
+					{Name: "Trigger parameter: API_TOKEN", Value: "[redacted]"},
+					{Name: "Trigger parameter: db", Value: `{"host":"db.local","password":"[redacted]","port":5432}`},
+					{Name: "Trigger parameter: db_password", Value: "[redacted]"},
+					{Name: "Trigger parameter: foo", Value: "bar"},
+				}))
+			})
+		})
+
+		Context("when statuses are configured", func() {
+			BeforeEach(func() {
+				request.Source.Statuses = []string{"SUCCEEDED"}
+				request.Source.StatusCheckInterval = "10ms"
+				request.Source.StatusCheckTimeout = "1s"
+			})
+
+			Context("and the execution succeeds", func() {
+				BeforeEach(func() {
+					spinnakerServer.AppendHandlers(
+						ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "ABC123", "status": "RUNNING"}),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "ABC123", "status": "SUCCEEDED", "startTime": 1000, "endTime": 62000}),
+					)
+				})
+
+				It("writes the execution id as the version", func() {
+					Expect(runErr).ToNot(HaveOccurred())
+					Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(5))
+					Expect(stdout.String()).To(ContainSubstring("ABC123"))
+				})
+
+				It("adds the final status and the duration to the metadata", func() {
+					var response concourse.OutResponse
+					Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+					Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Status", Value: "SUCCEEDED"}))
+					Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Duration", Value: "1m1s"}))
+				})
+			})
+
+			Context("and the stages of the execution progress", func() {
+				BeforeEach(func() {
+					spinnakerServer.AppendHandlers(
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"id":     "ABC123",
+							"status": "RUNNING",
+							"stages": []map[string]interface{}{
+								{"id": "S1", "name": "bake", "status": "RUNNING", "startTime": 1543414041000},
+								{"id": "S2", "name": "deploy-prod", "status": "NOT_STARTED"},
+							},
+						}),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"id":     "ABC123",
+							"status": "RUNNING",
+							"stages": []map[string]interface{}{
+								{"id": "S1", "name": "bake", "status": "RUNNING", "startTime": 1543414041000},
+								{"id": "S2", "name": "deploy-prod", "status": "NOT_STARTED"},
+							},
+						}),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"id":     "ABC123",
+							"status": "TERMINAL",
+							"stages": []map[string]interface{}{
+								{"id": "S1", "name": "bake", "status": "SUCCEEDED", "startTime": 1543414041000, "endTime": 1543414233000},
+								{"id": "S2", "name": "deploy-prod", "status": "TERMINAL", "startTime": 1543414233000, "endTime": 1543414243000},
+								{
+									"id": "S3", "name": "waitForManifestStable", "status": "TERMINAL", "parentStageId": "S2",
+									"context": map[string]interface{}{
+										"exception": map[string]interface{}{
+											"details": map[string]interface{}{"errors": []string{"Deployment exceeded its progress deadline"}},
+										},
+									},
+								},
+							},
+						}),
+					)
+				})
+
+				It("prints the transitions of the top level stages with their durations", func() {
+					Expect(stderr.String()).To(ContainSubstring("14:07:21 [bake] NOT_STARTED -> RUNNING\n.\n"))
+					Expect(stderr.String()).To(ContainSubstring("14:10:33 [bake] RUNNING -> SUCCEEDED (3m12s)\n"))
+					Expect(stderr.String()).To(ContainSubstring("14:10:43 [deploy-prod] NOT_STARTED -> TERMINAL (10s)\n"))
+					Expect(stderr.String()).ToNot(ContainSubstring("[waitForManifestStable]"))
+				})
+
+				It("prints the errors of the failed stages", func() {
+					Expect(runErr).To(MatchError("Pipeline execution reached a final state: TERMINAL"))
+					Expect(stderr.String()).To(ContainSubstring("Stage 'deploy-prod' failed with status TERMINAL\n"))
+					Expect(stderr.String()).To(ContainSubstring("Stage 'waitForManifestStable' failed with status TERMINAL:\n  Deployment exceeded its progress deadline\n"))
+				})
+			})
+
+			Context("and the execution does not reach them before the timeout", func() {
+				BeforeEach(func() {
+					request.Source.StatusCheckInterval = "1h"
+					request.Source.StatusCheckTimeout = "10ms"
+					spinnakerServer.AppendHandlers(
+						ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "ABC123", "status": "RUNNING"}),
+					)
+				})
+
+				It("leaves the execution running by default", func() {
+					Expect(runErr).To(MatchError("timed out waiting for configured status(es), left pipeline execution ABC123 running"))
+					Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(4))
+				})
+
+				Context("when on_timeout is cancel", func() {
+					BeforeEach(func() {
+						request.Params.OnTimeout = "cancel"
+						spinnakerServer.AppendHandlers(
+							ghttp.CombineHandlers(
+								ghttp.VerifyRequest("PUT", "/pipelines/ABC123/cancel", "reason=timed+out+waiting+for+configured+status%28es%29+in+concourse"),
+								ghttp.RespondWith(200, nil),
+							),
+						)
+					})
+
+					It("cancels the execution", func() {
+						Expect(runErr).To(MatchError("timed out waiting for configured status(es), canceled pipeline execution ABC123"))
+						Expect(stderr.String()).To(ContainSubstring("Canceling pipeline execution: ABC123"))
+					})
+
+					Context("with a cancel_reason", func() {
+						BeforeEach(func() {
+							request.Params.CancelReason = "too slow"
+							spinnakerServer.SetHandler(4, ghttp.CombineHandlers(
+								ghttp.VerifyRequest("PUT", "/pipelines/ABC123/cancel", "reason=too+slow"),
+								ghttp.RespondWith(200, nil),
+							))
+						})
+
+						It("sends the reason to spinnaker", func() {
+							Expect(runErr).To(MatchError("timed out waiting for configured status(es), canceled pipeline execution ABC123"))
+						})
+					})
+
+					Context("and spinnaker fails to cancel it", func() {
+						BeforeEach(func() {
+							spinnakerServer.SetHandler(4, ghttp.RespondWith(404, nil))
+						})
+
+						It("reports both the timeout and the failure", func() {
+							Expect(runErr).To(HaveOccurred())
+							Expect(runErr.Error()).To(HavePrefix("timed out waiting for configured status(es), failed to cancel pipeline execution ABC123: pipeline execution not found"))
+						})
+					})
+				})
+
+				Context("when on_timeout is pause", func() {
+					BeforeEach(func() {
+						request.Params.OnTimeout = "pause"
+						spinnakerServer.AppendHandlers(
+							ghttp.CombineHandlers(
+								ghttp.VerifyRequest("PUT", "/pipelines/ABC123/pause"),
+								ghttp.RespondWith(200, nil),
+							),
+						)
+					})
+
+					It("pauses the execution", func() {
+						Expect(runErr).To(MatchError("timed out waiting for configured status(es), paused pipeline execution ABC123"))
+						Expect(stderr.String()).To(ContainSubstring("Pausing pipeline execution: ABC123"))
+					})
+				})
+			})
+
+			Context("and the context is canceled while waiting", func() {
+				BeforeEach(func() {
+					var cancel context.CancelFunc
+					ctx, cancel = context.WithCancel(context.Background())
+					spinnakerServer.AppendHandlers(
+						ghttp.CombineHandlers(
+							func(http.ResponseWriter, *http.Request) { cancel() },
+							ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "ABC123", "status": "RUNNING"}),
+						),
+					)
+				})
+
+				It("stops polling and returns the context error", func() {
+					Expect(errors.Is(runErr, context.Canceled)).To(BeTrue())
+					Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(4))
+					Expect(stdout.Len()).To(BeZero())
+				})
+			})
+		})
+	})
+
+	Context("when the pipeline declares no parameters and strict_params is set", func() {
+		BeforeEach(func() {
+			request.Params.StrictParams = true
+			request.Params.TriggerParams = map[string]interface{}{"version": "1.0.0"}
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyJSON(`{"type":"concourse-resource","parameters":{"version":"1.0.0"}}`),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+				),
+			)
+		})
+
+		It("sends the params without a warning", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stderr.String()).ToNot(ContainSubstring("not declared"))
+		})
+	})
+
+	Context("when the pipeline declares parameters", func() {
+		BeforeEach(func() {
+			pipeline.Config["parameterConfig"] = []map[string]interface{}{
+				{"name": "version", "required": true},
+				{"name": "env", "default": "staging", "hasOptions": true, "options": []map[string]string{{"value": "staging"}, {"value": "prod"}}},
+			}
+			request.Params.TriggerParams = map[string]interface{}{"version": "1.0.0", "verison": "1.0.1"}
+		})
+
+		Context("and the params are valid", func() {
+			BeforeEach(func() {
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"type":"concourse-resource","parameters":{"version":"1.0.0","verison":"1.0.1","env":"staging"}}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+					),
+				)
+			})
+
+			It("fills in the defaults and warns about undeclared params", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(stderr.String()).To(ContainSubstring("warning: trigger parameters not declared by pipeline 'foo': verison"))
+			})
+		})
+
+		Context("and strict_params is set", func() {
+			BeforeEach(func() {
+				request.Params.StrictParams = true
+			})
+
+			It("refuses undeclared params without triggering the pipeline", func() {
+				Expect(runErr).To(MatchError("trigger parameters not declared by pipeline 'foo': verison"))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and the params are invalid", func() {
+			BeforeEach(func() {
+				request.Params.TriggerParams = map[string]interface{}{"env": "prdo"}
+			})
+
+			It("returns every problem without triggering the pipeline", func() {
+				Expect(runErr).To(MatchError(`invalid trigger parameters for pipeline 'foo': missing required parameter version; parameter env is "prdo", expected one of: staging, prod`))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+	})
+
+	Context("when trigger params files are given", func() {
+		var sourcesDir string
+
+		writeFile := func(name, contents string) {
+			Expect(ioutil.WriteFile(filepath.Join(sourcesDir, name), []byte(contents), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			sourcesDir, err = ioutil.TempDir("", "sources")
+			Expect(err).ToNot(HaveOccurred())
+			args = []string{sourcesDir}
+
+			writeFile("params.json", `{"from": "json", "json_only": [1, 2]}`)
+			writeFile("params.yml", "from: yaml\nyaml_only:\n  nested: true\n  list: [a, b]\n")
+			writeFile("build.env", "# build info\nexport from=dotenv\nQUOTED=\"a \\\"b\\\"\"\nSINGLE='c d'\n\nEMPTY=\n")
+			request.Params.TriggerParamsJSONFilePath = "params.json"
+			request.Params.TriggerParamsFiles = []string{"params.yml", "build.env"}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(sourcesDir)
+		})
+
+		Context("and they parse", func() {
+			BeforeEach(func() {
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"type":"concourse-resource","parameters":{
+							"from": "dotenv",
+							"json_only": [1, 2],
+							"yaml_only": {"nested": true, "list": ["a", "b"]},
+							"QUOTED": "a \"b\"",
+							"SINGLE": "c d",
+							"EMPTY": ""
+						}}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+					),
+				)
+			})
+
+			It("merges them in order, picking the parser by extension", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and a trigger_params_format is given", func() {
+			BeforeEach(func() {
+				writeFile("params.txt", "from: forced yaml\n")
+				request.Params.TriggerParamsJSONFilePath = ""
+				request.Params.TriggerParamsFiles = []string{"params.txt"}
+				request.Params.TriggerParamsFormat = "yaml"
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"type":"concourse-resource","parameters":{"from":"forced yaml"}}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+					),
+				)
+			})
+
+			It("uses it for every file", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and a json file does not parse", func() {
+			BeforeEach(func() {
+				writeFile("params.json", "{\n  \"a\": 1,\n  \"b\": oops\n}")
+			})
+
+			It("reports the file and line", func() {
+				Expect(runErr).To(MatchError(filepath.Join(sourcesDir, "params.json") + ":3: invalid character 'o' looking for beginning of value"))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and a yaml file does not parse", func() {
+			BeforeEach(func() {
+				writeFile("params.yml", "a: 1\nb: [unclosed\n")
+			})
+
+			It("reports the file and line", func() {
+				Expect(runErr).To(MatchError(filepath.Join(sourcesDir, "params.yml") + ":2: did not find expected ',' or ']'"))
+			})
+		})
+
+		Context("and a dotenv file does not parse", func() {
+			BeforeEach(func() {
+				writeFile("build.env", "A=1\nnot a pair\n")
+			})
+
+			It("reports the file and line", func() {
+				Expect(runErr).To(MatchError(filepath.Join(sourcesDir, "build.env") + `:2: expected KEY=VALUE, got: "not a pair"`))
+			})
+		})
+	})
+
+	Context("when artifacts are given", func() {
+		var sourcesDir string
+
+		writeFile := func(name, contents string) {
+			path := filepath.Join(sourcesDir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			sourcesDir, err = ioutil.TempDir("", "sources")
+			Expect(err).ToNot(HaveOccurred())
+			args = []string{sourcesDir}
+
+			writeFile("image/repository", "registry.example.com:5000/org/app\n")
+			writeFile("image/digest", "sha256:abc\n")
+			writeFile("image/tag", "1.0\n")
+			writeFile("manifests/deploy.yml", "kind: Deployment\n")
+			writeFile("release/url", "gs://bucket/app-1.2.tgz\n")
+			request.Params.ArtifactList = []concourse.Artifact{
+				{Type: "docker/image", From: "image"},
+				{Type: "embedded/base64", File: "manifests/deploy.yml"},
+				{Type: "gcs/object", ReferenceFile: "release/url", Version: "1.2"},
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(sourcesDir)
+		})
+
+		Context("and they can be built", func() {
+			BeforeEach(func() {
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+						ghttp.VerifyJSON(`{"type":"concourse-resource","artifacts":[
+							{"type":"docker/image","name":"registry.example.com:5000/org/app","version":"sha256:abc","reference":"registry.example.com:5000/org/app@sha256:abc"},
+							{"type":"embedded/base64","name":"deploy.yml","reference":"a2luZDogRGVwbG95bWVudAo="},
+							{"type":"gcs/object","name":"gs://bucket/app-1.2.tgz","version":"1.2","reference":"gs://bucket/app-1.2.tgz"}
+						]}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+					),
+				)
+			})
+
+			It("sends them with the trigger", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and the docker image has no digest", func() {
+			BeforeEach(func() {
+				Expect(os.Remove(filepath.Join(sourcesDir, "image/digest"))).To(Succeed())
+				request.Params.ArtifactList = request.Params.ArtifactList[:1]
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"type":"concourse-resource","artifacts":[
+							{"type":"docker/image","name":"registry.example.com:5000/org/app","version":"1.0","reference":"registry.example.com:5000/org/app:1.0"}
+						]}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+					),
+				)
+			})
+
+			It("references the image by tag", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and an artifacts_json_file is given", func() {
+			BeforeEach(func() {
+				writeFile("artifacts.json", `[{"type":"http/file","reference":"https://example.com/file"}]`)
+				request.Params.Artifacts = "artifacts.json"
+				request.Params.ArtifactList = []concourse.Artifact{{Type: "s3/object", Reference: "s3://bucket/key"}}
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyJSON(`{"type":"concourse-resource","artifacts":[
+							{"type":"http/file","reference":"https://example.com/file"},
+							{"type":"s3/object","name":"s3://bucket/key","reference":"s3://bucket/key"}
+						]}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+					),
+				)
+			})
+
+			It("sends the artifacts of the file first", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and the artifacts_json_file does not hold a list", func() {
+			BeforeEach(func() {
+				writeFile("artifacts.json", `{"type":"http/file"}`)
+				request.Params.Artifacts = "artifacts.json"
+			})
+
+			It("errors", func() {
+				Expect(runErr).To(MatchError("artifacts_json_file artifacts.json must contain an array of artifacts to be combined with artifacts"))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and an artifact has no reference", func() {
+			BeforeEach(func() {
+				request.Params.ArtifactList = append(request.Params.ArtifactList, concourse.Artifact{Type: "github/file"})
+			})
+
+			It("errors without triggering the pipeline", func() {
+				Expect(runErr).To(MatchError("artifacts[3] (github/file): reference or reference_file is required"))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and an artifact has no type", func() {
+			BeforeEach(func() {
+				request.Params.ArtifactList = []concourse.Artifact{{Reference: "s3://bucket/key"}}
+			})
+
+			It("errors", func() {
+				Expect(runErr).To(MatchError("artifacts[0]: type is required"))
+			})
+		})
+	})
+
+	Context("when embed_artifacts are given", func() {
+		var sourcesDir string
+
+		writeFile := func(name, contents string) {
+			path := filepath.Join(sourcesDir, name)
+			Expect(os.MkdirAll(filepath.Dir(path), 0755)).To(Succeed())
+			Expect(ioutil.WriteFile(path, []byte(contents), 0644)).To(Succeed())
+		}
+
+		BeforeEach(func() {
+			var err error
+			sourcesDir, err = ioutil.TempDir("", "sources")
+			Expect(err).ToNot(HaveOccurred())
+			args = []string{sourcesDir}
+
+			writeFile("rendered/app.yml", "a")
+			writeFile("rendered/db.yml", "b")
+			writeFile("values.yml", "c")
+			request.Params.EmbedArtifacts = map[string]string{
+				"values":    "values.yml",
+				"manifests": "rendered/*.yml",
+			}
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(sourcesDir)
+		})
+
+		Context("and the files are within the size limit", func() {
+			BeforeEach(func() {
+				writeFile("artifacts.json", `[{"type":"http/file","reference":"https://example.com/file"}]`)
+				request.Params.Artifacts = "artifacts.json"
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/pipelines/bar/foo"),
+						ghttp.VerifyJSON(`{"type":"concourse-resource","artifacts":[
+							{"type":"http/file","reference":"https://example.com/file"},
+							{"type":"embedded/base64","name":"manifests/app.yml","reference":"YQ=="},
+							{"type":"embedded/base64","name":"manifests/db.yml","reference":"Yg=="},
+							{"type":"embedded/base64","name":"values","reference":"Yw=="}
+						]}`),
+						ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+					),
+				)
+			})
+
+			It("embeds each file after the artifacts of artifacts_json_file", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and a file is larger than embed_artifacts_max_size", func() {
+			BeforeEach(func() {
+				writeFile("values.yml", "too large")
+				request.Params.EmbedArtifactsMaxSize = 4
+			})
+
+			It("errors without triggering the pipeline", func() {
+				Expect(runErr).To(MatchError("embed_artifacts values: " + filepath.Join(sourcesDir, "values.yml") + " is 9 bytes, larger than the 4 bytes allowed by embed_artifacts_max_size"))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and the files together are larger than embed_artifacts_max_total_size", func() {
+			BeforeEach(func() {
+				request.Params.EmbedArtifactsMaxTotalSize = 8
+			})
+
+			It("errors on the file that goes over the limit without triggering the pipeline", func() {
+				Expect(runErr).To(MatchError("embed_artifacts values: " + filepath.Join(sourcesDir, "values.yml") + " brings the embedded artifacts to 12 bytes once base64 encoded, more than the 8 bytes allowed by embed_artifacts_max_total_size"))
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(2))
+			})
+		})
+
+		Context("and a pattern matches no files", func() {
+			BeforeEach(func() {
+				request.Params.EmbedArtifacts["missing"] = "missing/*.yml"
+			})
+
+			It("errors", func() {
+				Expect(runErr).To(MatchError("embed_artifacts missing: no files match missing/*.yml"))
+			})
+		})
+	})
+
+	Context("when the trigger_params_format is not supported", func() {
+		BeforeEach(func() {
+			request.Params.TriggerParamsFormat = "toml"
+		})
+
+		It("returns an error without calling spinnaker", func() {
+			Expect(runErr).To(MatchError("unsupported trigger_params_format: toml, expected one of: json, yaml, dotenv"))
+			Expect(spinnakerServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when waiting for a stage", func() {
+		var executionStatus, stageStatus string
+
+		BeforeEach(func() {
+			request.Params.WaitForStage = "2"
+			request.Source.StatusCheckInterval = "1h"
+			executionStatus = "RUNNING"
+			stageStatus = "SUCCEEDED"
+			spinnakerServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+				func(w http.ResponseWriter, r *http.Request) {
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+						"id":     "ABC123",
+						"status": executionStatus,
+						"stages": []map[string]string{
+							{"id": "S1", "refId": "1", "name": "bake", "status": "SUCCEEDED"},
+							{"id": "S2", "refId": "2", "name": "deploy-prod", "status": stageStatus},
+							{"id": "S3", "refId": "3", "name": "verify", "status": "NOT_STARTED"},
+						},
+					})(w, r)
+				},
+			)
+		})
+
+		It("returns as soon as the stage succeeds, ignoring the pipeline status", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(stdout.String()).To(ContainSubstring("ABC123"))
+		})
+
+		Context("and the stage fails", func() {
+			BeforeEach(func() {
+				stageStatus = "TERMINAL"
+				executionStatus = "TERMINAL"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("Stage 'deploy-prod' reached a final state: TERMINAL"))
+				Expect(stdout.Len()).To(BeZero())
+			})
+		})
+
+		Context("and wait_for_stage_statuses are configured", func() {
+			BeforeEach(func() {
+				request.Params.WaitForStage = "deploy-prod"
+				request.Params.WaitForStageStatuses = []string{"FAILED_CONTINUE"}
+				stageStatus = "FAILED_CONTINUE"
+			})
+
+			It("returns when the stage reaches one of them", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("and the pipeline finishes before the stage does", func() {
+			BeforeEach(func() {
+				stageStatus = "NOT_STARTED"
+				executionStatus = "CANCELED"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("Pipeline execution reached a final state: CANCELED before stage 'deploy-prod' did"))
+			})
+		})
+
+		Context("and the pipeline has no such stage", func() {
+			BeforeEach(func() {
+				request.Params.WaitForStage = "deploy-staging"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("stage 'deploy-staging' not found in pipeline execution ABC123"))
+			})
+		})
+	})
+
+	Context("when the action is resume", func() {
+		BeforeEach(func() {
+			request.Params.Action = "resume"
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=1&pipelineConfigIds=foo-config-id&statuses=PAUSED"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]string{{"id": "ABC123", "status": "PAUSED"}}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/pipelines/ABC123/resume"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+		})
+
+		It("resumes the latest paused execution without triggering the pipeline", func() {
+			Expect(runErr).ToNot(HaveOccurred())
+			Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(4))
+			Expect(stderr.String()).To(ContainSubstring("Resuming pipeline execution: ABC123"))
+
+			var response concourse.OutResponse
+			Expect(json.Unmarshal(stdout.Bytes(), &response)).To(Succeed())
+			Expect(response.Version).To(Equal(concourse.Version{Ref: "ABC123"}))
+			Expect(response.Metadata).To(ContainElement(concourse.MetadataPair{Name: "Action", Value: "resume"}))
+		})
+	})
+
+	Context("when the action is judge", func() {
+		var stages []map[string]string
+
+		BeforeEach(func() {
+			request.Params.Action = "judge"
+			request.Params.JudgmentStatus = "continue"
+			stages = []map[string]string{
+				{"id": "STAGE1", "refId": "1", "type": "manualJudgment", "name": "Approve", "status": "SUCCEEDED"},
+				{"id": "STAGE2", "refId": "2", "type": "manualJudgment", "name": "Approve again", "status": "RUNNING"},
+			}
+			spinnakerServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "limit=1&pipelineConfigIds=foo-config-id&statuses=RUNNING"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]string{{"id": "ABC123", "status": "RUNNING"}}),
+				),
+				func(w http.ResponseWriter, r *http.Request) {
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/pipelines/ABC123"),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"id": "ABC123", "stages": stages}),
+					)(w, r)
+				},
+			)
+		})
+
+		Context("and a single stage is waiting", func() {
+			BeforeEach(func() {
+				spinnakerServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("PATCH", "/pipelines/ABC123/stages/STAGE2"),
+						ghttp.VerifyJSON(`{"judgmentStatus":"continue"}`),
+						ghttp.RespondWith(200, nil),
+					),
+				)
+			})
+
+			It("judges it in the latest running execution", func() {
+				Expect(runErr).ToNot(HaveOccurred())
+				Expect(spinnakerServer.ReceivedRequests()).To(HaveLen(5))
+				Expect(stdout.String()).To(ContainSubstring("ABC123"))
+			})
+		})
+
+		Context("and several stages are waiting", func() {
+			BeforeEach(func() {
+				stages[0]["status"] = "RUNNING"
+			})
+
+			It("asks for a judgment_stage", func() {
+				Expect(runErr).To(MatchError("2 manual judgment stages are waiting for a judgment in pipeline execution ABC123, set judgment_stage to pick one"))
+			})
+
+			Context("and the judgment_stage is a refId", func() {
+				BeforeEach(func() {
+					request.Params.JudgmentStage = "1"
+					spinnakerServer.AppendHandlers(
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("PATCH", "/pipelines/ABC123/stages/STAGE1"),
+							ghttp.RespondWith(200, nil),
+						),
+					)
+				})
+
+				It("judges the matching stage", func() {
+					Expect(runErr).ToNot(HaveOccurred())
+				})
+			})
+		})
+
+		Context("and no stage is waiting", func() {
+			BeforeEach(func() {
+				stages[1]["status"] = "SUCCEEDED"
+			})
+
+			It("returns an error", func() {
+				Expect(runErr).To(MatchError("no manual judgment stage is waiting for a judgment in pipeline execution ABC123"))
+			})
+		})
+
+		Context("and the judgment_status is missing", func() {
+			BeforeEach(func() {
+				request.Params.JudgmentStatus = ""
+			})
+
+			It("returns an error without calling spinnaker", func() {
+				Expect(runErr).To(MatchError(`action judge requires a judgment_status of continue or stop, got: ""`))
+				Expect(spinnakerServer.ReceivedRequests()).To(BeEmpty())
+			})
+		})
+	})
+
+	Context("when on_timeout is not supported", func() {
+		BeforeEach(func() {
+			request.Params.OnTimeout = "explode"
+		})
+
+		It("returns an error without calling spinnaker", func() {
+			Expect(runErr).To(MatchError("unsupported on_timeout: explode, expected one of: cancel, pause, leave"))
+			Expect(spinnakerServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+
+	Context("when no sources directory is given", func() {
+		BeforeEach(func() {
+			args = nil
+		})
+
+		It("returns an error without calling spinnaker", func() {
+			Expect(runErr).To(HaveOccurred())
+			Expect(spinnakerServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,276 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package out
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mitchellh/colorstring"
+	"gopkg.in/yaml.v2"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+)
+
+const (
+	formatJSON   = "json"
+	formatYAML   = "yaml"
+	formatDotenv = "dotenv"
+)
+
+// triggerParameters merges trigger_params with the contents of
+// trigger_params_json_file and then of every trigger_params_files in order.
+// Values can be of any type; on conflicting keys the value from a later file
+// replaces the earlier one as a whole.
+func (c *command) triggerParameters() (map[string]interface{}, error) {
+	triggerParams := map[string]interface{}{}
+	for key, value := range c.request.Params.TriggerParams {
+		triggerParams[key] = expandEnv(value)
+	}
+
+	var paths []string
+	if len(c.request.Params.TriggerParamsJSONFilePath) > 0 {
+		paths = append(paths, c.request.Params.TriggerParamsJSONFilePath)
+	}
+	paths = append(paths, c.request.Params.TriggerParamsFiles...)
+
+	for _, path := range paths {
+		fileParams, err := readParamsFile(filepath.Join(c.sourcesDir, path), c.request.Params.TriggerParamsFormat)
+		if err != nil {
+			return nil, err
+		}
+		for key, value := range fileParams {
+			triggerParams[key] = value
+		}
+	}
+	return triggerParams, nil
+}
+
+// checkTriggerParameters checks params against the parameterConfig of the
+// pipeline and fills in its defaults. Params the pipeline does not declare
+// are a warning, or an error with strict_params.
+func (c *command) checkTriggerParameters(triggerParams map[string]interface{}) (map[string]interface{}, error) {
+	pipelineConfig := c.spinClient.PipelineConfig()
+	triggerParams, unknown, err := pipelineConfig.ApplyParameterConfig(triggerParams)
+	if err != nil {
+		return nil, err
+	}
+	if len(unknown) > 0 {
+		if c.request.Params.StrictParams {
+			return nil, fmt.Errorf("trigger parameters not declared by pipeline '%s': %s", pipelineConfig.Name, strings.Join(unknown, ", "))
+		}
+		concourse.Sayf(c.stderr, colorstring.Color("[yellow]warning: trigger parameters not declared by pipeline '%s': %s\n"), pipelineConfig.Name, strings.Join(unknown, ", "))
+	}
+	return triggerParams, nil
+}
+
+// paramsFormat is the explicit format, or the one matching the extension of
+// the file. Files without a known extension are read as JSON.
+func paramsFormat(path, format string) string {
+	if format != "" {
+		return format
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yml", ".yaml":
+		return formatYAML
+	case ".env":
+		return formatDotenv
+	}
+	if filepath.Base(path) == ".env" {
+		return formatDotenv
+	}
+	return formatJSON
+}
+
+// readParamsFile parses a params file, reporting parse errors as
+// path:line: message.
+func readParamsFile(path, format string) (map[string]interface{}, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch paramsFormat(path, format) {
+	case formatYAML:
+		return parseYAMLParams(path, contents)
+	case formatDotenv:
+		return parseDotenvParams(path, contents)
+	default:
+		return parseJSONParams(path, contents)
+	}
+}
+
+func parseJSONParams(path string, contents []byte) (map[string]interface{}, error) {
+	var params map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(contents))
+	// keep numbers as they are written, large integers included
+	decoder.UseNumber()
+	err := decoder.Decode(&params)
+	if err != nil {
+		var offset int64
+		switch e := err.(type) {
+		case *json.SyntaxError:
+			offset = e.Offset
+		case *json.UnmarshalTypeError:
+			offset = e.Offset
+		default:
+			offset = decoder.InputOffset()
+		}
+		return nil, fmt.Errorf("%s:%d: %s", path, lineAt(contents, offset), err)
+	}
+	return params, nil
+}
+
+// lineAt is the line number of the byte offset in contents.
+func lineAt(contents []byte, offset int64) int {
+	if offset > int64(len(contents)) {
+		offset = int64(len(contents))
+	}
+	return bytes.Count(contents[:offset], []byte("\n")) + 1
+}
+
+var yamlLinePattern = regexp.MustCompile(`line (\d+): (.*)`)
+
+func parseYAMLParams(path string, contents []byte) (map[string]interface{}, error) {
+	var params map[string]interface{}
+	err := yaml.Unmarshal(contents, &params)
+	if err != nil {
+		// yaml reports errors as "yaml: line N: message"
+		if match := yamlLinePattern.FindStringSubmatch(err.Error()); match != nil {
+			return nil, fmt.Errorf("%s:%s: %s", path, match[1], match[2])
+		}
+		return nil, fmt.Errorf("%s: %s", path, err)
+	}
+	for key, value := range params {
+		params[key] = convertYAML(value)
+	}
+	return params, nil
+}
+
+// convertYAML turns the map[interface{}]interface{} yaml decodes nested
+// objects into into map[string]interface{}, so they can be sent as JSON.
+func convertYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		converted := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			converted[fmt.Sprint(key)] = convertYAML(nested)
+		}
+		return converted
+	case []interface{}:
+		converted := make([]interface{}, len(v))
+		for i, nested := range v {
+			converted[i] = convertYAML(nested)
+		}
+		return converted
+	}
+	return value
+}
+
+// parseDotenvParams reads KEY=VALUE lines, ignoring blank lines, comments and
+// a leading "export". Values are strings, optionally quoted.
+func parseDotenvParams(path string, contents []byte) (map[string]interface{}, error) {
+	params := map[string]interface{}{}
+	for i, line := range strings.Split(string(contents), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		separator := strings.Index(line, "=")
+		if separator < 1 {
+			return nil, fmt.Errorf("%s:%d: expected KEY=VALUE, got: %q", path, i+1, line)
+		}
+		key := strings.TrimSpace(line[:separator])
+		value := strings.TrimSpace(line[separator+1:])
+
+		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
+			unquoted, err := strconv.Unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("%s:%d: invalid quoted value for %s: %s", path, i+1, key, err)
+			}
+			value = unquoted
+		} else if len(value) >= 2 && value[0] == '\'' && value[len(value)-1] == '\'' {
+			value = value[1 : len(value)-1]
+		}
+		params[key] = value
+	}
+	return params, nil
+}
+
+// expandEnv expands ${VAR} references in every string of a param value,
+// however deeply nested.
+func expandEnv(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return os.ExpandEnv(v)
+	case map[string]interface{}:
+		expanded := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			expanded[key] = expandEnv(nested)
+		}
+		return expanded
+	case []interface{}:
+		expanded := make([]interface{}, len(v))
+		for i, nested := range v {
+			expanded[i] = expandEnv(nested)
+		}
+		return expanded
+	}
+	return value
+}
+
+var secretKeyPattern = regexp.MustCompile(`(?i)pass(word|wd)?|secret|token|credential|api[_-]?key|private[_-]?key`)
+
+const redacted = "[redacted]"
+
+// redact hides the values of parameters whose keys look like they hold
+// secrets, including the keys of nested objects, so they do not end up on
+// the build page.
+func redact(key string, value interface{}) interface{} {
+	if secretKeyPattern.MatchString(key) {
+		return redacted
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redactedMap := make(map[string]interface{}, len(v))
+		for nestedKey, nested := range v {
+			redactedMap[nestedKey] = redact(nestedKey, nested)
+		}
+		return redactedMap
+	case []interface{}:
+		redactedSlice := make([]interface{}, len(v))
+		for i, nested := range v {
+			redactedSlice[i] = redact("", nested)
+		}
+		return redactedSlice
+	}
+	return value
+}
+
+// metadataValue shows strings as they are and any other value as JSON.
+func metadataValue(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(contents)
+}
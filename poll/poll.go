@@ -0,0 +1,106 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package poll
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+// ErrTimeout is returned by Poll when the execution is not done within the
+// timeout of the poller.
+var ErrTimeout = errors.New("timed out waiting for pipeline execution")
+
+// Check decides from the latest state of an execution whether polling is
+// done. An error stops polling as a failure.
+type Check func(spinnaker.PipelineExecution) (bool, error)
+
+// Poller polls a pipeline execution until a check is satisfied, printing the
+// progress of its stages along the way.
+type Poller struct {
+	spinClient spinnaker.SpinClient
+	interval   time.Duration
+	timeout    time.Duration
+	progress   *stageProgress
+}
+
+func NewPoller(spinClient spinnaker.SpinClient, stderr io.Writer, interval, timeout time.Duration) *Poller {
+	return &Poller{
+		spinClient: spinClient,
+		interval:   interval,
+		timeout:    timeout,
+		progress:   newStageProgress(stderr),
+	}
+}
+
+// Poll fetches the execution right away and then every interval until check
+// is done, fails, the timeout passes or ctx is done. It always returns the
+// last state of the execution it fetched, and prints the errors of its failed
+// stages when check fails.
+func (p *Poller) Poll(ctx context.Context, pipelineExecutionID string, check Check) (spinnaker.PipelineExecution, error) {
+	var pipelineExecution spinnaker.PipelineExecution
+
+	done, err := p.poll(ctx, pipelineExecutionID, check, &pipelineExecution)
+	if done || err != nil {
+		return pipelineExecution, err
+	}
+
+	pollTicker := time.NewTicker(p.interval)
+	defer pollTicker.Stop()
+	timeoutTimer := time.NewTimer(p.timeout)
+	defer timeoutTimer.Stop()
+
+	for {
+		select {
+		case <-pollTicker.C:
+			done, err := p.poll(ctx, pipelineExecutionID, check, &pipelineExecution)
+			if done || err != nil {
+				return pipelineExecution, err
+			}
+		case <-timeoutTimer.C:
+			p.progress.finish()
+			return pipelineExecution, ErrTimeout
+		case <-ctx.Done():
+			p.progress.finish()
+			return pipelineExecution, ctx.Err()
+		}
+	}
+}
+
+// poll fetches the execution into pipelineExecution, leaving it as it was
+// when fetching fails, and checks it.
+func (p *Poller) poll(ctx context.Context, pipelineExecutionID string, check Check, pipelineExecution *spinnaker.PipelineExecution) (bool, error) {
+	polled, err := p.spinClient.GetPipelineExecution(ctx, pipelineExecutionID)
+	if err != nil {
+		p.progress.finish()
+		return false, err
+	}
+	*pipelineExecution = polled
+	changed := p.progress.update(polled)
+
+	done, err := check(polled)
+	if err != nil {
+		p.progress.finish()
+		p.progress.reportFailures(polled)
+		return false, err
+	}
+	if done {
+		p.progress.finish()
+		return true, nil
+	}
+	if !changed {
+		p.progress.tick()
+	}
+	return false, nil
+}
@@ -0,0 +1,157 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package poll_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/poll"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker/gatetest"
+)
+
+var _ = Describe("Poller", func() {
+	var (
+		gateServer *ghttp.Server
+		source     concourse.Source
+		stderr     *bytes.Buffer
+		poller     *poll.Poller
+		ctx        context.Context
+		interval   time.Duration
+		timeout    time.Duration
+		check      poll.Check
+
+		pipelineExecution spinnaker.PipelineExecution
+		pollErr           error
+	)
+
+	isTerminal := func(pipelineExecution spinnaker.PipelineExecution) (bool, error) {
+		return pipelineExecution.Status.IsTerminal(), nil
+	}
+
+	BeforeEach(func() {
+		pipeline := gatetest.NewPipeline("app", "pipeline")
+		gateServer = ghttp.NewServer()
+		gateServer.AppendHandlers(pipeline.ClientHandlers()...)
+		source = pipeline.Source(gateServer.URL())
+		stderr = &bytes.Buffer{}
+		ctx = context.Background()
+		interval = 10 * time.Millisecond
+		timeout = time.Second
+		check = isTerminal
+	})
+
+	JustBeforeEach(func() {
+		spinClient, err := spinnaker.NewClient(context.Background(), source)
+		Expect(err).ToNot(HaveOccurred())
+		poller = poll.NewPoller(spinClient, stderr, interval, timeout)
+		pipelineExecution, pollErr = poller.Poll(ctx, "EX1", check)
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	Context("when the execution gets done", func() {
+		BeforeEach(func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/pipelines/EX1"),
+					ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "RUNNING"}),
+				),
+				ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "RUNNING"}),
+				ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "SUCCEEDED"}),
+			)
+		})
+
+		It("polls until the check is done and returns the last state", func() {
+			Expect(pollErr).ToNot(HaveOccurred())
+			Expect(pipelineExecution.Status).To(Equal(spinnaker.StatusSucceeded))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(5))
+			Expect(stderr.String()).To(Equal("..\n"))
+		})
+	})
+
+	Context("when the check fails", func() {
+		BeforeEach(func() {
+			check = func(spinnaker.PipelineExecution) (bool, error) {
+				return false, errors.New("boom")
+			}
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+					"id":     "EX1",
+					"status": "TERMINAL",
+					"stages": []map[string]string{{"id": "S1", "name": "deploy", "status": "TERMINAL"}},
+				}),
+			)
+		})
+
+		It("stops polling and prints the failed stages", func() {
+			Expect(pollErr).To(MatchError("boom"))
+			Expect(pipelineExecution.ID).To(Equal("EX1"))
+			Expect(stderr.String()).To(ContainSubstring("Stage 'deploy' failed with status TERMINAL"))
+		})
+	})
+
+	Context("when the execution is not done in time", func() {
+		BeforeEach(func() {
+			interval = time.Hour
+			timeout = 10 * time.Millisecond
+			gateServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "RUNNING"}),
+			)
+		})
+
+		It("returns ErrTimeout with the last state it fetched", func() {
+			Expect(pollErr).To(Equal(poll.ErrTimeout))
+			Expect(pipelineExecution.Status).To(Equal(spinnaker.StatusRunning))
+		})
+	})
+
+	Context("when the context is done", func() {
+		BeforeEach(func() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(context.Background())
+			cancel()
+		})
+
+		It("returns the error of the context without fetching the execution", func() {
+			Expect(errors.Is(pollErr, context.Canceled)).To(BeTrue())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("when the context is done while waiting", func() {
+		BeforeEach(func() {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithCancel(context.Background())
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					func(http.ResponseWriter, *http.Request) { cancel() },
+					ghttp.RespondWithJSONEncoded(200, map[string]string{"id": "EX1", "status": "RUNNING"}),
+				),
+			)
+		})
+
+		It("returns the error of the context", func() {
+			Expect(errors.Is(pollErr, context.Canceled)).To(BeTrue())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+		})
+	})
+})
@@ -0,0 +1,110 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package poll
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+// stageProgress prints the status transitions of the stages of an execution
+// between polls, so the build log shows which stage is running or failed.
+type stageProgress struct {
+	w        io.Writer
+	statuses map[string]spinnaker.ExecutionStatus
+	dotted   bool
+}
+
+func newStageProgress(w io.Writer) *stageProgress {
+	return &stageProgress{
+		w:        w,
+		statuses: map[string]spinnaker.ExecutionStatus{},
+	}
+}
+
+// update prints a line per top level stage whose status changed since the
+// last update and reports whether any did.
+func (p *stageProgress) update(pipelineExecution spinnaker.PipelineExecution) bool {
+	changed := false
+	for _, stage := range pipelineExecution.Stages {
+		if stage.ParentStageID != "" {
+			continue
+		}
+		previous, seen := p.statuses[stage.ID]
+		if !seen {
+			previous = spinnaker.StatusNotStarted
+		}
+		p.statuses[stage.ID] = stage.Status
+		if stage.Status == previous {
+			continue
+		}
+
+		line := fmt.Sprintf("%s [%s] %s -> %s", p.timestamp(stage), stage.Name, previous, stage.Status)
+		if duration := stage.Duration(); duration > 0 {
+			line += fmt.Sprintf(" (%s)", duration.Round(time.Second))
+		}
+		p.println(line)
+		changed = true
+	}
+	return changed
+}
+
+// tick prints a "." for a poll that did not change anything.
+func (p *stageProgress) tick() {
+	concourse.Sayf(p.w, ".")
+	p.dotted = true
+}
+
+// reportFailures prints the error messages of the failed stages of an
+// execution, including the synthetic stages that usually hold them.
+func (p *stageProgress) reportFailures(pipelineExecution spinnaker.PipelineExecution) {
+	for _, stage := range pipelineExecution.Stages {
+		if stage.Status != spinnaker.StatusTerminal && stage.Status != spinnaker.StatusFailedContinue {
+			continue
+		}
+		messages := stage.ErrorMessages()
+		if len(messages) == 0 {
+			p.println(fmt.Sprintf("Stage '%s' failed with status %s", stage.Name, stage.Status))
+			continue
+		}
+		p.println(fmt.Sprintf("Stage '%s' failed with status %s:\n  %s", stage.Name, stage.Status, strings.Join(messages, "\n  ")))
+	}
+}
+
+// finish ends the line of dots printed since the last transition, if any.
+func (p *stageProgress) finish() {
+	if p.dotted {
+		concourse.Sayf(p.w, "\n")
+		p.dotted = false
+	}
+}
+
+func (p *stageProgress) println(line string) {
+	p.finish()
+	concourse.Sayf(p.w, "%s\n", line)
+}
+
+// timestamp is when the stage reached its current status, as far as the
+// stage records it.
+func (p *stageProgress) timestamp(stage spinnaker.Stage) string {
+	t := time.Now()
+	switch {
+	case stage.Status.IsTerminal() && stage.EndTime > 0:
+		t = time.Unix(0, stage.EndTime*int64(time.Millisecond))
+	case stage.Status == spinnaker.StatusRunning && stage.StartTime > 0:
+		t = time.Unix(0, stage.StartTime*int64(time.Millisecond))
+	}
+	return t.UTC().Format("15:04:05")
+}
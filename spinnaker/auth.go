@@ -0,0 +1,373 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+)
+
+// Authenticator authenticates the requests sent to gate.
+type Authenticator interface {
+	// PrepareTransport configures the transport before any request is sent,
+	// e.g. with a client certificate.
+	PrepareTransport(transport *http.Transport) error
+	// DecorateRequest adds credentials to a request before it is sent.
+	DecorateRequest(req *http.Request) error
+	// Refresh renews the credentials after gate responded with a 401 and
+	// reports whether the request should be sent again.
+	Refresh() (bool, error)
+}
+
+// NewAuthenticator returns the authenticator selected by the auth block of
+// the source. Authenticators that log in to gate themselves do so through
+// the given transport. The token endpoint of oauth2 is a different server,
+// so it only shares the CA bundle of the transport and is verified by the
+// default rules rather than gate's server_name or insecure_skip_verify.
+func NewAuthenticator(source concourse.Source, transport *http.Transport) (Authenticator, error) {
+	switch source.Auth.Type {
+	case "", "x509":
+		return NewX509Authenticator(source.X509Cert, source.X509Key)
+	case "bearer":
+		if source.Auth.Token == "" {
+			return nil, fmt.Errorf("auth type bearer requires a token")
+		}
+		return NewBearerAuthenticator(source.Auth.Token), nil
+	case "oauth2":
+		if source.Auth.TokenURL == "" || source.Auth.ClientID == "" {
+			return nil, fmt.Errorf("auth type oauth2 requires a token_url and client_id")
+		}
+		return NewOAuth2Authenticator(&http.Client{Transport: tokenTransport(transport)}, source.Auth.TokenURL, source.Auth.ClientID, source.Auth.ClientSecret, source.Auth.Scopes), nil
+	case "basic":
+		if source.Auth.Username == "" {
+			return nil, fmt.Errorf("auth type basic requires a username")
+		}
+		return NewBasicAuthenticator(transport, fmt.Sprintf("%s/login", source.SpinnakerAPI), source.Auth.Username, source.Auth.Password)
+	case "none":
+		return NoAuthenticator{}, nil
+	default:
+		return nil, fmt.Errorf("unsupported auth type: %s", source.Auth.Type)
+	}
+}
+
+func tokenTransport(transport *http.Transport) *http.Transport {
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if transport.TLSClientConfig != nil {
+		tlsConfig.RootCAs = transport.TLSClientConfig.RootCAs
+	}
+	return &http.Transport{TLSClientConfig: tlsConfig}
+}
+
+// NewAuthenticatedClient prepares the transport with the authenticator and
+// returns a client that authenticates every request, refreshing the
+// credentials and retrying once when gate responds with a 401.
+func NewAuthenticatedClient(auth Authenticator, transport *http.Transport) (*http.Client, error) {
+	err := auth.PrepareTransport(transport)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: &authTransport{base: transport, auth: auth}}, nil
+}
+
+type authTransport struct {
+	base http.RoundTripper
+	auth Authenticator
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	response, err := t.send(req.Clone(req.Context()))
+	if err != nil || response.StatusCode != http.StatusUnauthorized {
+		return response, err
+	}
+	if req.Body != nil && req.GetBody == nil {
+		return response, nil
+	}
+
+	retry, err := t.auth.Refresh()
+	if err != nil {
+		response.Body.Close()
+		return nil, err
+	}
+	if !retry {
+		return response, nil
+	}
+	response.Body.Close()
+
+	retryReq := req.Clone(req.Context())
+	if req.GetBody != nil {
+		retryReq.Body, err = req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return t.send(retryReq)
+}
+
+func (t *authTransport) send(req *http.Request) (*http.Response, error) {
+	err := t.auth.DecorateRequest(req)
+	if err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.base.RoundTrip(req)
+}
+
+// NoAuthenticator sends requests to gate without any credentials.
+type NoAuthenticator struct{}
+
+func (NoAuthenticator) PrepareTransport(transport *http.Transport) error { return nil }
+func (NoAuthenticator) DecorateRequest(req *http.Request) error          { return nil }
+func (NoAuthenticator) Refresh() (bool, error)                           { return false, nil }
+
+// X509Authenticator presents a client certificate to gate.
+type X509Authenticator struct {
+	cert tls.Certificate
+}
+
+func NewX509Authenticator(cert, key string) (*X509Authenticator, error) {
+	keyPair, err := tls.X509KeyPair([]byte(cert), []byte(key))
+	if err != nil {
+		return nil, err
+	}
+	return &X509Authenticator{cert: keyPair}, nil
+}
+
+func (a *X509Authenticator) PrepareTransport(transport *http.Transport) error {
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.Certificates = []tls.Certificate{a.cert}
+	return nil
+}
+
+func (a *X509Authenticator) DecorateRequest(req *http.Request) error { return nil }
+func (a *X509Authenticator) Refresh() (bool, error)                  { return false, nil }
+
+// BearerAuthenticator sends a static token with every request.
+type BearerAuthenticator struct {
+	token string
+}
+
+func NewBearerAuthenticator(token string) *BearerAuthenticator {
+	return &BearerAuthenticator{token: token}
+}
+
+func (a *BearerAuthenticator) PrepareTransport(transport *http.Transport) error { return nil }
+
+func (a *BearerAuthenticator) DecorateRequest(req *http.Request) error {
+	req.Header.Set("Authorization", "Bearer "+a.token)
+	return nil
+}
+
+func (a *BearerAuthenticator) Refresh() (bool, error) { return false, nil }
+
+// OAuth2Authenticator fetches tokens with the oauth2 client credentials
+// grant and caches them until shortly before they expire.
+type OAuth2Authenticator struct {
+	client       *http.Client
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// tokens are refreshed this long before the expiry reported by the token endpoint
+const tokenExpiryDelta = 10 * time.Second
+
+func NewOAuth2Authenticator(client *http.Client, tokenURL, clientID, clientSecret string, scopes []string) *OAuth2Authenticator {
+	return &OAuth2Authenticator{
+		client:       client,
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+	}
+}
+
+func (a *OAuth2Authenticator) PrepareTransport(transport *http.Transport) error { return nil }
+
+func (a *OAuth2Authenticator) DecorateRequest(req *http.Request) error {
+	token, err := a.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+// a 401 means the cached token was revoked, fetch a new one
+func (a *OAuth2Authenticator) Refresh() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.token = ""
+	return true, nil
+}
+
+func (a *OAuth2Authenticator) Token(ctx context.Context) (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.token != "" && (a.expiry.IsZero() || time.Now().Before(a.expiry)) {
+		return a.token, nil
+	}
+
+	form := url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {a.clientID},
+		"client_secret": {a.clientSecret},
+	}
+	if len(a.scopes) > 0 {
+		form.Set("scope", strings.Join(a.scopes, " "))
+	}
+
+	response, err := postForm(ctx, a.client, a.tokenURL, form)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return "", err
+	}
+	if response.StatusCode >= 400 {
+		return "", fmt.Errorf("oauth2 token endpoint responded with status code: %d, body: %s", response.StatusCode, string(body))
+	}
+
+	var tokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	err = json.Unmarshal(body, &tokenResponse)
+	if err != nil {
+		return "", err
+	}
+	if tokenResponse.AccessToken == "" {
+		return "", fmt.Errorf("oauth2 token endpoint did not return an access token")
+	}
+
+	a.token = tokenResponse.AccessToken
+	a.expiry = time.Time{}
+	if tokenResponse.ExpiresIn > 0 {
+		a.expiry = time.Now().Add(time.Duration(tokenResponse.ExpiresIn)*time.Second - tokenExpiryDelta)
+	}
+	return a.token, nil
+}
+
+// BasicAuthenticator logs in to gate with a username and password on the
+// first request and reuses the session cookie for every request after that.
+type BasicAuthenticator struct {
+	client   *http.Client
+	loginURL string
+	username string
+	password string
+
+	mu       sync.Mutex
+	loggedIn bool
+}
+
+func NewBasicAuthenticator(transport http.RoundTripper, loginURL, username, password string) (*BasicAuthenticator, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{
+		Transport: transport,
+		Jar:       jar,
+		// gate answers a login with a redirect, the session cookie is all we need
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return &BasicAuthenticator{
+		client:   client,
+		loginURL: loginURL,
+		username: username,
+		password: password,
+	}, nil
+}
+
+func (a *BasicAuthenticator) PrepareTransport(transport *http.Transport) error { return nil }
+
+func (a *BasicAuthenticator) DecorateRequest(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if !a.loggedIn {
+		err := a.login(req.Context())
+		if err != nil {
+			return err
+		}
+	}
+
+	req.Header.Del("Cookie")
+	for _, cookie := range a.client.Jar.Cookies(req.URL) {
+		req.AddCookie(cookie)
+	}
+	return nil
+}
+
+// a 401 means the session expired, log in again on the next request
+func (a *BasicAuthenticator) Refresh() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.loggedIn = false
+	return true, nil
+}
+
+func (a *BasicAuthenticator) login(ctx context.Context) error {
+	response, err := postForm(ctx, a.client, a.loginURL, url.Values{
+		"username": {a.username},
+		"password": {a.password},
+	})
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// gate redirects back to the login page when the credentials are rejected
+	location, _ := response.Location()
+	if response.StatusCode >= 400 || (location != nil && location.Query()["error"] != nil) {
+		return fmt.Errorf("spinnaker login failed for user %s with status code: %d", a.username, response.StatusCode)
+	}
+
+	a.loggedIn = true
+	return nil
+}
+
+// postForm is http.Client.PostForm, abandoned when ctx is done
+func postForm(ctx context.Context, client *http.Client, endpoint string, form url.Values) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return client.Do(request)
+}
@@ -0,0 +1,197 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"bytes"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+var _ = Describe("Authenticators", func() {
+	var (
+		gateServer *ghttp.Server
+		transport  *http.Transport
+		client     *http.Client
+		auth       spinnaker.Authenticator
+	)
+
+	BeforeEach(func() {
+		gateServer = ghttp.NewServer()
+		transport = &http.Transport{}
+		auth = spinnaker.NoAuthenticator{}
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		client, err = spinnaker.NewAuthenticatedClient(auth, transport)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	Describe("NewAuthenticator", func() {
+		It("selects the authenticator from the auth type", func() {
+			source := concourse.Source{X509Cert: serverCert, X509Key: serverKey}
+			Expect(spinnaker.NewAuthenticator(source, transport)).To(BeAssignableToTypeOf(&spinnaker.X509Authenticator{}))
+
+			source.Auth = concourse.Auth{Type: "bearer", Token: "some-token"}
+			Expect(spinnaker.NewAuthenticator(source, transport)).To(BeAssignableToTypeOf(&spinnaker.BearerAuthenticator{}))
+
+			source.Auth = concourse.Auth{Type: "oauth2", TokenURL: "https://uaa.example.com/oauth/token", ClientID: "some-client"}
+			Expect(spinnaker.NewAuthenticator(source, transport)).To(BeAssignableToTypeOf(&spinnaker.OAuth2Authenticator{}))
+
+			source.Auth = concourse.Auth{Type: "basic", Username: "some-user"}
+			Expect(spinnaker.NewAuthenticator(source, transport)).To(BeAssignableToTypeOf(&spinnaker.BasicAuthenticator{}))
+
+			source.Auth = concourse.Auth{Type: "none"}
+			Expect(spinnaker.NewAuthenticator(source, transport)).To(Equal(spinnaker.NoAuthenticator{}))
+		})
+	})
+
+	Context("NoAuthenticator", func() {
+		It("sends requests without credentials and does not retry a 401", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/applications"),
+					func(w http.ResponseWriter, req *http.Request) {
+						Expect(req.Header.Get("Authorization")).To(BeEmpty())
+						Expect(req.Cookies()).To(BeEmpty())
+					},
+					ghttp.RespondWith(401, nil),
+				),
+			)
+
+			response, err := client.Get(gateServer.URL() + "/applications")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(401))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("X509Authenticator", func() {
+		It("adds the client certificate to the transport", func() {
+			x509Auth, err := spinnaker.NewX509Authenticator(serverCert, serverKey)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(x509Auth.PrepareTransport(transport)).To(Succeed())
+			Expect(transport.TLSClientConfig.Certificates).To(HaveLen(1))
+		})
+
+		It("returns an error for an invalid key pair", func() {
+			_, err := spinnaker.NewX509Authenticator("", "")
+			Expect(err).To(HaveOccurred())
+		})
+	})
+
+	Context("BearerAuthenticator", func() {
+		BeforeEach(func() {
+			auth = spinnaker.NewBearerAuthenticator("some-token")
+		})
+
+		It("sends the token and does not retry a 401", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("Authorization", "Bearer some-token"),
+					ghttp.RespondWith(401, nil),
+				),
+			)
+
+			response, err := client.Get(gateServer.URL() + "/applications")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(401))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("OAuth2Authenticator", func() {
+		var tokenServer *ghttp.Server
+
+		BeforeEach(func() {
+			tokenServer = ghttp.NewServer()
+			auth = spinnaker.NewOAuth2Authenticator(&http.Client{}, tokenServer.URL()+"/oauth/token", "some-client", "some-secret", nil)
+		})
+
+		AfterEach(func() {
+			tokenServer.Close()
+		})
+
+		It("fetches a new token and retries once when the token is rejected", func() {
+			tokenServer.AppendHandlers(
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"access_token": "revoked-token"}),
+				ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"access_token": "fresh-token"}),
+			)
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("Authorization", "Bearer revoked-token"),
+					ghttp.RespondWith(401, nil),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyHeaderKV("Authorization", "Bearer fresh-token"),
+					ghttp.VerifyJSON(`{"type":"manual"}`),
+					ghttp.RespondWith(202, nil),
+				),
+			)
+
+			response, err := client.Post(gateServer.URL()+"/pipelines/app/pipeline", "application/json", bytes.NewBufferString(`{"type":"manual"}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(202))
+			Expect(tokenServer.ReceivedRequests()).To(HaveLen(2))
+		})
+	})
+
+	Context("BasicAuthenticator", func() {
+		BeforeEach(func() {
+			var err error
+			auth, err = spinnaker.NewBasicAuthenticator(transport, gateServer.URL()+"/login", "some-user", "some-password")
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("logs in on the first request and sends the session cookie", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/login"),
+					ghttp.VerifyFormKV("username", "some-user"),
+					ghttp.VerifyFormKV("password", "some-password"),
+					ghttp.RespondWith(302, nil, http.Header{
+						"Location":   {"/"},
+						"Set-Cookie": {"SESSION=some-session; Path=/"},
+					}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/applications"),
+					ghttp.VerifyHeaderKV("Cookie", "SESSION=some-session"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+
+			response, err := client.Get(gateServer.URL() + "/applications")
+			Expect(err).ToNot(HaveOccurred())
+			Expect(response.StatusCode).To(Equal(200))
+		})
+
+		It("fails the request when the login is rejected", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(302, nil, http.Header{"Location": {"/login?error"}}),
+			)
+
+			_, err := client.Get(gateServer.URL() + "/applications")
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("spinnaker login failed for user some-user"))
+		})
+	})
+})
@@ -11,186 +11,325 @@ package spinnaker
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 
 	"github.com/pivotal-cf/spinnaker-resource/concourse"
 )
 
 type SpinClient struct {
-	sourceConfig concourse.Source
-	client       *http.Client
+	sourceConfig   concourse.Source
+	client         *http.Client
+	pipelineConfig PipelineConfig
 }
 
-func NewClient(source concourse.Source) (SpinClient, error) {
-
-	cert, err := tls.X509KeyPair([]byte(source.X509Cert), []byte(source.X509Key))
+// NewClient looks up the configured application and pipeline in gate. ctx
+// bounds these requests only, every request made with the client takes its
+// own context.
+func NewClient(ctx context.Context, source concourse.Source) (SpinClient, error) {
 
+	tlsConfig, err := newTLSConfig(source)
 	if err != nil {
 		return SpinClient{}, err
 	}
-	tlsConfig := &tls.Config{
-		MinVersion:               tls.VersionTLS12,
-		PreferServerCipherSuites: true,
-		Certificates:             []tls.Certificate{cert},
-		//TODO Do something!!
-		InsecureSkipVerify: true,
-	}
 
 	tr := &http.Transport{
 		TLSClientConfig: tlsConfig,
 	}
 
-	client := &http.Client{Transport: tr}
+	auth, err := NewAuthenticator(source, tr)
+	if err != nil {
+		return SpinClient{}, err
+	}
+
+	client, err := NewAuthenticatedClient(auth, tr)
+	if err != nil {
+		return SpinClient{}, err
+	}
 
-	res, err := client.Get(fmt.Sprintf("%s/applications/%s", source.SpinnakerAPI, source.SpinnakerApplication))
+	retryPolicy, err := NewRetryPolicy(source)
 	if err != nil {
 		return SpinClient{}, err
-	} else if res.StatusCode == 404 {
-		err = fmt.Errorf("spinnaker application %s not found", source.SpinnakerApplication)
+	}
+	client.Transport = &retryTransport{base: client.Transport, policy: retryPolicy}
+
+	res, err := send(ctx, client, http.MethodGet, fmt.Sprintf("%s/applications/%s", source.SpinnakerAPI, source.SpinnakerApplication), nil)
+	if err != nil {
 		return SpinClient{}, err
-	} else if res.StatusCode >= 400 {
-		body, err := ioutil.ReadAll(res.Body)
-		if err == nil {
-			err = fmt.Errorf("spinnaker api responded with status code: %d, body: %s", res.StatusCode, string(body))
-		}
+	}
+	_, err = readResponse(res, ErrApplicationNotFound)
+	if err != nil {
 		return SpinClient{}, err
 	}
 
-	res, err = client.Get(fmt.Sprintf("%s/applications/%s/pipelineConfigs", source.SpinnakerAPI, source.SpinnakerApplication))
+	res, err = send(ctx, client, http.MethodGet, fmt.Sprintf("%s/applications/%s/pipelineConfigs", source.SpinnakerAPI, source.SpinnakerApplication), nil)
 	if err != nil {
 		return SpinClient{}, err
-	} else if res.StatusCode >= 400 {
-		body, err := ioutil.ReadAll(res.Body)
-		if err == nil {
-			err = fmt.Errorf("spinnaker api responded with status code: %d, body: %s", res.StatusCode, string(body))
-			return SpinClient{}, err
-		}
-	} else {
-		var pipelineConfigs []map[string]interface{}
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			return SpinClient{}, err
-		}
+	}
+	body, err := readResponse(res, ErrApplicationNotFound)
+	if err != nil {
+		return SpinClient{}, err
+	}
 
-		err = json.Unmarshal(body, &pipelineConfigs)
-		if err != nil {
-			return SpinClient{}, err
-		}
+	// only the config of the configured pipeline is decoded in full, so the
+	// configs of other pipelines of the application cannot fail the client
+	var pipelineConfigs []json.RawMessage
+	err = json.Unmarshal(body, &pipelineConfigs)
+	if err != nil {
+		return SpinClient{}, err
+	}
 
-		found := false
-		for _, pc := range pipelineConfigs {
-			if pc["name"].(string) == source.SpinnakerPipeline {
-				found = true
-				break
-			}
+	var pipelineConfig PipelineConfig
+	found := false
+	for _, rawConfig := range pipelineConfigs {
+		var named struct {
+			Name string `json:"name"`
 		}
-		if !found {
-			err = fmt.Errorf("spinnaker pipeline %s not found", source.SpinnakerPipeline)
-			return SpinClient{}, err
+		if json.Unmarshal(rawConfig, &named) != nil || named.Name != source.SpinnakerPipeline {
+			continue
+		}
+		err = json.Unmarshal(rawConfig, &pipelineConfig)
+		if err != nil {
+			return SpinClient{}, fmt.Errorf("unable to read the config of pipeline %s: %w", source.SpinnakerPipeline, err)
 		}
+		found = true
+		break
+	}
+	if !found {
+		return SpinClient{}, fmt.Errorf("%w: %s", ErrPipelineNotFound, source.SpinnakerPipeline)
 	}
 
 	spinClient := SpinClient{
-		sourceConfig: source,
-		client:       client,
+		sourceConfig:   source,
+		client:         client,
+		pipelineConfig: pipelineConfig,
 	}
 	return spinClient, nil
 }
 
-func (c *SpinClient) GetPipelineExecution(pipelineExecutionID string) (map[string]interface{}, error) {
-	var pipelineExecutionMetadata map[string]interface{}
-	bytes, err := c.GetPipelineExecutionRaw(pipelineExecutionID)
+// verifies gate against the system roots plus any configured CA bundle,
+// unless the source explicitly opts out with insecure_skip_verify
+func newTLSConfig(source concourse.Source) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:               tls.VersionTLS12,
+		PreferServerCipherSuites: true,
+		ServerName:               source.ServerName,
+		InsecureSkipVerify:       source.InsecureSkipVerify,
+	}
+
+	if source.CACert == "" {
+		return tlsConfig, nil
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil || rootCAs == nil {
+		rootCAs = x509.NewCertPool()
+	}
+	if !rootCAs.AppendCertsFromPEM([]byte(source.CACert)) {
+		return nil, fmt.Errorf("no valid PEM certificates found in spinnaker_ca_cert")
+	}
+	tlsConfig.RootCAs = rootCAs
+
+	return tlsConfig, nil
+}
+
+func (c *SpinClient) GetPipelineExecution(ctx context.Context, pipelineExecutionID string) (PipelineExecution, error) {
+	var pipelineExecution PipelineExecution
+	bytes, err := c.GetPipelineExecutionRaw(ctx, pipelineExecutionID)
 	if err != nil {
-		return nil, err
+		return pipelineExecution, err
 	}
-	err = json.Unmarshal(bytes, &pipelineExecutionMetadata)
+	err = json.Unmarshal(bytes, &pipelineExecution)
 	if err != nil {
-		return nil, err
+		return pipelineExecution, fmt.Errorf("unable to parse pipeline execution %s: %s", pipelineExecutionID, err)
 	}
-	return pipelineExecutionMetadata, nil
+	return pipelineExecution, nil
 }
 
-func (c *SpinClient) GetPipelineExecutionRaw(pipelineExecutionID string) ([]byte, error) {
+// PipelineConfig is the config of the configured pipeline, as fetched when
+// the client was created.
+func (c *SpinClient) PipelineConfig() PipelineConfig {
+	return c.pipelineConfig
+}
+
+func (c *SpinClient) GetPipelineExecutionRaw(ctx context.Context, pipelineExecutionID string) ([]byte, error) {
 	url := fmt.Sprintf("%s/pipelines/%s", c.sourceConfig.SpinnakerAPI, pipelineExecutionID)
-	response, err := c.client.Get(url)
+	response, err := send(ctx, c.client, http.MethodGet, url, nil)
 	if err != nil {
 		return nil, err
-	} else if response.StatusCode == 404 {
-		err = fmt.Errorf("pipeline execution ID not found (ID: %s)", pipelineExecutionID)
+	}
+	return readResponse(response, ErrExecutionNotFound)
+}
+
+// returns the latest executions matching the query, newest first
+func (c *SpinClient) GetPipelineExecutions(ctx context.Context, query ExecutionQuery) ([]PipelineExecution, error) {
+	var pipelineExecutions []PipelineExecution
+
+	url := fmt.Sprintf("%s/executions?%s", c.sourceConfig.SpinnakerAPI, c.executionQueryValues(query).Encode())
+
+	response, err := send(ctx, c.client, http.MethodGet, url, nil)
+	if err != nil {
 		return nil, err
-	} else if response.StatusCode >= 400 {
-		body, err := ioutil.ReadAll(response.Body)
-		if err == nil {
-			err = fmt.Errorf("spinnaker api responded with status code: %d, body: %s", response.StatusCode, string(body))
-		}
+	}
+	body, err := readResponse(response, ErrPipelineNotFound)
+	if err != nil {
 		return nil, err
 	}
-	body, err := ioutil.ReadAll(response.Body)
+	err = json.Unmarshal(body, &pipelineExecutions)
 	if err != nil {
 		return nil, err
 	}
-	return body, nil
+	return pipelineExecutions, nil
 }
 
-//returns the last 25 spinnaker pipeline executions
-func (c *SpinClient) GetPipelineExecutions() ([]PipelineExecution, error) {
-	var pipelineExecutions []PipelineExecution
-
-	//TODO What does expand do ??
-	url := fmt.Sprintf("%s/applications/%s/pipelines?limit=25", c.sourceConfig.SpinnakerAPI, c.sourceConfig.SpinnakerApplication)
+// defaults to the executions of the configured pipeline
+func (c *SpinClient) executionQueryValues(query ExecutionQuery) url.Values {
+	pipelineConfigIDs := query.PipelineConfigIDs
+	if len(pipelineConfigIDs) == 0 {
+		pipelineConfigIDs = []string{c.pipelineConfig.ID}
+	}
 
-	if response, err := c.client.Get(url); err != nil {
-		return nil, err
-	} else if response.StatusCode >= 400 {
-		body, err := ioutil.ReadAll(response.Body)
-		if err == nil {
-			err = fmt.Errorf("spinnaker api responded with status code: %d, body: %s", response.StatusCode, string(body))
-		}
-		return nil, err
-	} else {
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return nil, err
-		}
-		err = json.Unmarshal(body, &pipelineExecutions)
-		if err != nil {
-			return nil, err
-		}
-		return pipelineExecutions, nil
+	values := url.Values{
+		"pipelineConfigIds": {strings.Join(pipelineConfigIDs, ",")},
+	}
+	if len(query.Statuses) > 0 {
+		values.Set("statuses", strings.Join(query.Statuses, ","))
 	}
+	if query.Limit > 0 {
+		values.Set("limit", strconv.Itoa(query.Limit))
+	}
+	if query.Expand {
+		values.Set("expand", "true")
+	}
+	return values
 }
 
-func (c *SpinClient) InvokePipelineExecution(body []byte) (PipelineExecution, error) {
+func (c *SpinClient) InvokePipelineExecution(ctx context.Context, body []byte) (PipelineExecution, error) {
 
 	pipelineExecution := PipelineExecution{}
 
 	url := fmt.Sprintf("%s/pipelines/%s/%s", c.sourceConfig.SpinnakerAPI, c.sourceConfig.SpinnakerApplication, c.sourceConfig.SpinnakerPipeline)
 
-	if response, err := c.client.Post(url, "application/json", bytes.NewBuffer(body)); err != nil {
+	response, err := send(ctx, c.client, http.MethodPost, url, body)
+	if err != nil {
 		return pipelineExecution, err
-	} else if response.StatusCode >= 400 {
-		body, err := ioutil.ReadAll(response.Body)
-		if err == nil {
-			err = fmt.Errorf("spinnaker api responded with status code: %d, body: %s", response.StatusCode, string(body))
-		}
+	}
+	body, err = readResponse(response, ErrPipelineNotFound)
+	if err != nil {
 		return pipelineExecution, err
-	} else {
-		body, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			return pipelineExecution, err
-		}
-		var Data map[string]interface{}
-		err = json.Unmarshal(body, &Data)
-		if err != nil {
-			return pipelineExecution, err
-		}
+	}
+
+	var Data struct {
+		Ref string `json:"ref"`
+	}
+	err = json.Unmarshal(body, &Data)
+	if err != nil {
+		return pipelineExecution, err
+	}
+
+	// ref has the form /pipelines/{id}
+	ref := strings.Split(Data.Ref, "/")
+	if len(ref) != 3 || ref[2] == "" {
+		return pipelineExecution, fmt.Errorf("spinnaker api responded with an unexpected pipeline execution ref: %q", Data.Ref)
+	}
+	pipelineExecution.ID = ref[2]
+	return pipelineExecution, nil
+}
+
+// CancelPipelineExecution cancels a running execution, recording reason
+// against it in spinnaker.
+func (c *SpinClient) CancelPipelineExecution(ctx context.Context, pipelineExecutionID, reason string) error {
+	values := url.Values{}
+	if reason != "" {
+		values.Set("reason", reason)
+	}
+	return c.updatePipelineExecution(ctx, pipelineExecutionID, "cancel", values)
+}
+
+func (c *SpinClient) PausePipelineExecution(ctx context.Context, pipelineExecutionID string) error {
+	return c.updatePipelineExecution(ctx, pipelineExecutionID, "pause", nil)
+}
 
-		pipelineExecution.ID = strings.Split(Data["ref"].(string), "/")[2]
-		return pipelineExecution, nil
+func (c *SpinClient) ResumePipelineExecution(ctx context.Context, pipelineExecutionID string) error {
+	return c.updatePipelineExecution(ctx, pipelineExecutionID, "resume", nil)
+}
+
+func (c *SpinClient) updatePipelineExecution(ctx context.Context, pipelineExecutionID, operation string, values url.Values) error {
+	url := fmt.Sprintf("%s/pipelines/%s/%s", c.sourceConfig.SpinnakerAPI, pipelineExecutionID, operation)
+	if len(values) > 0 {
+		url += "?" + values.Encode()
+	}
+
+	response, err := send(ctx, c.client, http.MethodPut, url, nil)
+	if err != nil {
+		return err
+	}
+	_, err = readResponse(response, ErrExecutionNotFound)
+	return err
+}
+
+// JudgeStage approves or rejects a manual judgment stage of an execution.
+func (c *SpinClient) JudgeStage(ctx context.Context, pipelineExecutionID, stageID string, judgment Judgment) error {
+	body, err := json.Marshal(judgment)
+	if err != nil {
+		return err
+	}
+
+	url := fmt.Sprintf("%s/pipelines/%s/stages/%s", c.sourceConfig.SpinnakerAPI, pipelineExecutionID, stageID)
+	response, err := send(ctx, c.client, http.MethodPatch, url, body)
+	if err != nil {
+		return err
+	}
+	_, err = readResponse(response, ErrExecutionNotFound)
+	return err
+}
+
+// ExecutionURL links to the execution in Deck, or is empty when no
+// spinnaker_ui_url is configured.
+func (c *SpinClient) ExecutionURL(pipelineExecutionID string) string {
+	if c.sourceConfig.SpinnakerUIURL == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/#/applications/%s/executions/details/%s", strings.TrimSuffix(c.sourceConfig.SpinnakerUIURL, "/"), c.sourceConfig.SpinnakerApplication, pipelineExecutionID)
+}
+
+// FetchArtifact downloads the contents of an artifact through gate, using
+// the artifact accounts configured in spinnaker.
+func (c *SpinClient) FetchArtifact(ctx context.Context, artifact Artifact) ([]byte, error) {
+	body, err := json.Marshal(artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/artifacts/fetch", c.sourceConfig.SpinnakerAPI)
+	response, err := send(ctx, c.client, http.MethodPut, url, body)
+	if err != nil {
+		return nil, err
+	}
+	return readResponse(response, nil)
+}
+
+// send makes a request to gate that is abandoned, along with any retries,
+// when ctx is done. A body is sent as JSON.
+func send(ctx context.Context, client *http.Client, method, url string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
 	}
+	return client.Do(request)
 }
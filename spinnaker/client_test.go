@@ -10,6 +10,9 @@ Unless required by applicable law or agreed to in writing, software distributed
 package spinnaker_test
 
 import (
+	"context"
+	"encoding/pem"
+	"errors"
 	"net/http"
 
 	. "github.com/onsi/ginkgo"
@@ -17,6 +20,7 @@ import (
 	"github.com/onsi/gomega/ghttp"
 	"github.com/pivotal-cf/spinnaker-resource/concourse"
 	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker/gatetest"
 )
 
 const serverCert = `-----BEGIN CERTIFICATE-----
@@ -91,7 +95,7 @@ var _ = Describe("Spinnaker Client", func() {
 					X509Cert:             "",
 					X509Key:              "",
 				}
-				_, err := spinnaker.NewClient(source)
+				_, err := spinnaker.NewClient(context.Background(), source)
 				Expect(err).To(HaveOccurred())
 			})
 		})
@@ -124,10 +128,19 @@ var _ = Describe("Spinnaker Client", func() {
 					X509Cert:             serverCert,
 					X509Key:              serverKey,
 				}
-				_, err := spinnaker.NewClient(source)
+				_, err := spinnaker.NewClient(context.Background(), source)
 
-				Expect(err).To(HaveOccurred())
-				Expect(err.Error()).To(Equal("spinnaker application " + applicationName + " not found"))
+				Expect(errors.Is(err, spinnaker.ErrApplicationNotFound)).To(BeTrue())
+
+				var apiErr *spinnaker.APIError
+				Expect(errors.As(err, &apiErr)).To(BeTrue())
+				Expect(apiErr.StatusCode).To(Equal(404))
+				Expect(apiErr.Exception).To(Equal("com.netflix.spinnaker.kork.web.exceptions.NotFoundException"))
+				Expect(apiErr.Message).To(Equal("Application not found (id: nvidiw)"))
+				Expect(apiErr.URL).To(Equal(spinnakerServer.URL() + "/applications/" + applicationName))
+				Expect(err.Error()).To(Equal("spinnaker application not found: spinnaker api responded with status code: 404, " +
+					"exception: com.netflix.spinnaker.kork.web.exceptions.NotFoundException, message: Application not found (id: nvidiw) " +
+					"(GET " + spinnakerServer.URL() + "/applications/" + applicationName + ")"))
 			})
 		})
 
@@ -172,10 +185,10 @@ var _ = Describe("Spinnaker Client", func() {
 						X509Cert:             serverCert,
 						X509Key:              serverKey,
 					}
-					_, err := spinnaker.NewClient(source)
+					_, err := spinnaker.NewClient(context.Background(), source)
 
-					Expect(err).To(HaveOccurred())
-					Expect(err.Error()).To(Equal("spinnaker pipeline " + pipelineName + " not found"))
+					Expect(errors.Is(err, spinnaker.ErrPipelineNotFound)).To(BeTrue())
+					Expect(err.Error()).To(Equal("spinnaker pipeline not found: " + pipelineName))
 				})
 			})
 
@@ -201,11 +214,546 @@ var _ = Describe("Spinnaker Client", func() {
 						X509Cert:             serverCert,
 						X509Key:              serverKey,
 					}
-					_, err := spinnaker.NewClient(source)
+					_, err := spinnaker.NewClient(context.Background(), source)
 
 					Expect(err).ToNot(HaveOccurred())
 				})
 			})
+
+			Context("Given parameters with values that are not strings", func() {
+				BeforeEach(func() {
+					pipelineConfigHandler = ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", MatchRegexp(".*/applications/"+applicationName+"/pipelineConfigs")),
+						ghttp.RespondWith(statusCode, `[
+							{"name": "other_pipeline", "parameterConfig": [{"name": "debug", "required": "yes", "default": {"level": 1}}]},
+							{"name": "existent_pipeline", "parameterConfig": [
+								{"name": "replicas", "default": 3, "hasOptions": true, "options": [{"value": 1}, {"value": 3}]},
+								{"name": "canary", "default": false}
+							]}
+						]`),
+					)
+				})
+
+				It("only decodes the configured pipeline and keeps the values as their JSON text", func() {
+					source := concourse.Source{
+						SpinnakerAPI:         spinnakerServer.URL(),
+						SpinnakerApplication: applicationName,
+						SpinnakerPipeline:    "existent_pipeline",
+						X509Cert:             serverCert,
+						X509Key:              serverKey,
+					}
+					spinClient, err := spinnaker.NewClient(context.Background(), source)
+					Expect(err).ToNot(HaveOccurred())
+
+					parameters := spinClient.PipelineConfig().ParameterConfig
+					Expect(parameters).To(HaveLen(2))
+					Expect(parameters[0].Default).To(BeEquivalentTo("3"))
+					Expect(parameters[0].Options).To(Equal([]spinnaker.ParameterOption{{Value: "1"}, {Value: "3"}}))
+					Expect(parameters[1].Default).To(BeEquivalentTo("false"))
+				})
+			})
+		})
+	})
+
+	Context("When listing pipeline executions", func() {
+		var (
+			gateServer *ghttp.Server
+			spinClient spinnaker.SpinClient
+		)
+
+		BeforeEach(func() {
+			pipeline := gatetest.NewPipeline("existent_app", "existent_pipeline")
+			gateServer = ghttp.NewServer()
+			gateServer.AppendHandlers(pipeline.ClientHandlers()...)
+
+			var err error
+			spinClient, err = spinnaker.NewClient(context.Background(), pipeline.Source(gateServer.URL()))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			gateServer.Close()
+		})
+
+		It("defaults to the executions of the configured pipeline", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "pipelineConfigIds=existent_pipeline-config-id"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{
+						{"id": "EX1", "status": "RUNNING"},
+					}),
+				),
+			)
+
+			executions, err := spinClient.GetPipelineExecutions(context.Background(), spinnaker.ExecutionQuery{})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(executions).To(HaveLen(1))
+			Expect(executions[0].Status).To(Equal(spinnaker.StatusRunning))
+		})
+
+		It("sends every query option to gate", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/executions", "expand=true&limit=10&pipelineConfigIds=config-1%2Cconfig-2&statuses=SUCCEEDED%2CTERMINAL"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{}),
+				),
+			)
+
+			executions, err := spinClient.GetPipelineExecutions(context.Background(), spinnaker.ExecutionQuery{
+				PipelineConfigIDs: []string{"config-1", "config-2"},
+				Statuses:          []string{"SUCCEEDED", "TERMINAL"},
+				Limit:             10,
+				Expand:            true,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(executions).To(BeEmpty())
+		})
+	})
+
+	Context("When updating a pipeline execution", func() {
+		var (
+			gateServer *ghttp.Server
+			spinClient spinnaker.SpinClient
+		)
+
+		BeforeEach(func() {
+			pipeline := gatetest.NewPipeline("existent_app", "existent_pipeline")
+			gateServer = ghttp.NewServer()
+			gateServer.AppendHandlers(pipeline.ClientHandlers()...)
+
+			var err error
+			spinClient, err = spinnaker.NewClient(context.Background(), pipeline.Source(gateServer.URL()))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			gateServer.Close()
+		})
+
+		It("cancels the execution with a reason", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/pipelines/EX1/cancel", "reason=took+too+long"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+
+			Expect(spinClient.CancelPipelineExecution(context.Background(), "EX1", "took too long")).To(Succeed())
+		})
+
+		It("pauses the execution", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/pipelines/EX1/pause"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+
+			Expect(spinClient.PausePipelineExecution(context.Background(), "EX1")).To(Succeed())
+		})
+
+		It("resumes the execution", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/pipelines/EX1/resume"),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+
+			Expect(spinClient.ResumePipelineExecution(context.Background(), "EX1")).To(Succeed())
+		})
+
+		It("judges a manual judgment stage", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PATCH", "/pipelines/EX1/stages/STAGE1"),
+					ghttp.VerifyContentType("application/json"),
+					ghttp.VerifyJSON(`{"judgmentStatus":"continue","judgmentInput":"lgtm"}`),
+					ghttp.RespondWith(200, nil),
+				),
+			)
+
+			err := spinClient.JudgeStage(context.Background(), "EX1", "STAGE1", spinnaker.Judgment{
+				JudgmentStatus: spinnaker.JudgmentContinue,
+				JudgmentInput:  "lgtm",
+			})
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("fetches the contents of an artifact", func() {
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("PUT", "/artifacts/fetch"),
+					ghttp.VerifyJSON(`{"type":"gcs/object","reference":"gs://bucket/manifest.yml","artifactAccount":"gcs"}`),
+					ghttp.RespondWith(200, "kind: Deployment"),
+				),
+			)
+
+			contents, err := spinClient.FetchArtifact(context.Background(), spinnaker.Artifact{
+				Type:            "gcs/object",
+				Reference:       "gs://bucket/manifest.yml",
+				ArtifactAccount: "gcs",
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(string(contents)).To(Equal("kind: Deployment"))
+		})
+
+		It("returns ErrExecutionNotFound for an unknown execution", func() {
+			gateServer.AppendHandlers(ghttp.RespondWith(404, nil))
+
+			err := spinClient.CancelPipelineExecution(context.Background(), "EX1", "")
+			Expect(errors.Is(err, spinnaker.ErrExecutionNotFound)).To(BeTrue())
+		})
+	})
+
+	Context("When linking to an execution in deck", func() {
+		var (
+			gateServer *ghttp.Server
+			source     concourse.Source
+		)
+
+		BeforeEach(func() {
+			pipeline := gatetest.NewPipeline("existent_app", "existent_pipeline")
+			gateServer = ghttp.NewServer()
+			gateServer.AppendHandlers(pipeline.ClientHandlers()...)
+			source = pipeline.Source(gateServer.URL())
+		})
+
+		AfterEach(func() {
+			gateServer.Close()
+		})
+
+		It("builds the url of the execution details", func() {
+			source.SpinnakerUIURL = "https://deck.example.com/"
+			spinClient, err := spinnaker.NewClient(context.Background(), source)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(spinClient.ExecutionURL("EX1")).To(Equal("https://deck.example.com/#/applications/existent_app/executions/details/EX1"))
+		})
+
+		It("is empty without a spinnaker_ui_url", func() {
+			spinClient, err := spinnaker.NewClient(context.Background(), source)
+			Expect(err).ToNot(HaveOccurred())
+
+			Expect(spinClient.ExecutionURL("EX1")).To(BeEmpty())
+		})
+	})
+
+	Context("When gate is served over TLS", func() {
+		var (
+			tlsServer *ghttp.Server
+			source    concourse.Source
+		)
+
+		BeforeEach(func() {
+			tlsServer = ghttp.NewTLSServer()
+			tlsServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/applications/existent_app"),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/applications/existent_app/pipelineConfigs"),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}}),
+				),
+			)
+			source = concourse.Source{
+				SpinnakerAPI:         tlsServer.URL(),
+				SpinnakerApplication: "existent_app",
+				SpinnakerPipeline:    "existent_pipeline",
+				X509Cert:             serverCert,
+				X509Key:              serverKey,
+			}
+		})
+
+		AfterEach(func() {
+			tlsServer.Close()
+		})
+
+		Context("Given no CA certificate is configured", func() {
+			It("refuses to trust the server certificate", func() {
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("certificate"))
+			})
+
+			It("connects when insecure_skip_verify is explicitly enabled", func() {
+				source.InsecureSkipVerify = true
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+			})
+		})
+
+		Context("Given the CA certificate of the server", func() {
+			BeforeEach(func() {
+				source.CACert = serverCert + string(pem.EncodeToMemory(&pem.Block{
+					Type:  "CERTIFICATE",
+					Bytes: tlsServer.HTTPTestServer.Certificate().Raw,
+				}))
+			})
+
+			It("verifies the server certificate", func() {
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("verifies the server certificate against the configured server name", func() {
+				source.ServerName = "example.com"
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+			})
+
+			It("fails when the server name does not match the certificate", func() {
+				source.ServerName = "gate.example.org"
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).To(HaveOccurred())
+			})
+		})
+
+		Context("Given a CA bundle without any certificates", func() {
+			It("returns an error", func() {
+				source.CACert = "not a certificate"
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).To(MatchError("no valid PEM certificates found in spinnaker_ca_cert"))
+			})
+		})
+	})
+
+	Context("When authenticating without a client certificate", func() {
+		var (
+			gateServer  *ghttp.Server
+			tokenServer *ghttp.Server
+			source      concourse.Source
+		)
+
+		gateHandlers := func(token string) []http.HandlerFunc {
+			return []http.HandlerFunc{
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/applications/existent_app"),
+					ghttp.VerifyHeaderKV("Authorization", "Bearer "+token),
+					ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/applications/existent_app/pipelineConfigs"),
+					ghttp.VerifyHeaderKV("Authorization", "Bearer "+token),
+					ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}}),
+				),
+			}
+		}
+
+		BeforeEach(func() {
+			gateServer = ghttp.NewServer()
+			tokenServer = ghttp.NewServer()
+			source = concourse.Source{
+				SpinnakerAPI:         gateServer.URL(),
+				SpinnakerApplication: "existent_app",
+				SpinnakerPipeline:    "existent_pipeline",
+			}
+		})
+
+		AfterEach(func() {
+			gateServer.Close()
+			tokenServer.Close()
+		})
+
+		Context("Given a static bearer token", func() {
+			It("sends the token with every request without requiring a client certificate", func() {
+				gateServer.AppendHandlers(gateHandlers("some-token")...)
+				source.Auth = concourse.Auth{Type: "bearer", Token: "some-token"}
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+			})
+
+			It("returns an error when no token is configured", func() {
+				source.Auth = concourse.Auth{Type: "bearer"}
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).To(MatchError("auth type bearer requires a token"))
+			})
+		})
+
+		Context("Given oauth2 client credentials", func() {
+			BeforeEach(func() {
+				source.Auth = concourse.Auth{
+					Type:         "oauth2",
+					TokenURL:     tokenServer.URL() + "/oauth/token",
+					ClientID:     "some-client",
+					ClientSecret: "some-secret",
+					Scopes:       []string{"openid", "spinnaker"},
+				}
+			})
+
+			It("fetches a token once and sends it with every request", func() {
+				tokenServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						ghttp.VerifyFormKV("grant_type", "client_credentials"),
+						ghttp.VerifyFormKV("client_id", "some-client"),
+						ghttp.VerifyFormKV("client_secret", "some-secret"),
+						ghttp.VerifyFormKV("scope", "openid spinnaker"),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+							"access_token": "fetched-token",
+							"token_type":   "bearer",
+							"expires_in":   3600,
+						}),
+					),
+				)
+				gateServer.AppendHandlers(gateHandlers("fetched-token")...)
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(tokenServer.ReceivedRequests()).To(HaveLen(1))
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+			})
+
+			It("returns an error when the token endpoint rejects the client", func() {
+				tokenServer.AppendHandlers(
+					ghttp.RespondWithJSONEncoded(401, map[string]interface{}{"error": "invalid_client"}),
+				)
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("oauth2 token endpoint responded with status code: 401"))
+				Expect(gateServer.ReceivedRequests()).To(BeEmpty())
+			})
+
+			Context("When the token endpoint is served over TLS", func() {
+				var tlsTokenServer *ghttp.Server
+
+				BeforeEach(func() {
+					tlsTokenServer = ghttp.NewTLSServer()
+					tlsTokenServer.AppendHandlers(
+						ghttp.CombineHandlers(
+							ghttp.VerifyRequest("POST", "/oauth/token"),
+							ghttp.RespondWithJSONEncoded(200, map[string]interface{}{
+								"access_token": "fetched-token",
+								"token_type":   "bearer",
+								"expires_in":   3600,
+							}),
+						),
+					)
+					source.Auth.TokenURL = tlsTokenServer.URL() + "/oauth/token"
+				})
+
+				AfterEach(func() {
+					tlsTokenServer.Close()
+				})
+
+				It("verifies the token endpoint against its own host rather than the server_name of gate", func() {
+					gateServer.AppendHandlers(gateHandlers("fetched-token")...)
+					source.CACert = string(pem.EncodeToMemory(&pem.Block{
+						Type:  "CERTIFICATE",
+						Bytes: tlsTokenServer.HTTPTestServer.Certificate().Raw,
+					}))
+					source.ServerName = "gate.example.org"
+
+					_, err := spinnaker.NewClient(context.Background(), source)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(tlsTokenServer.ReceivedRequests()).To(HaveLen(1))
+				})
+
+				It("does not skip verification of the token endpoint when insecure_skip_verify is enabled", func() {
+					source.InsecureSkipVerify = true
+
+					_, err := spinnaker.NewClient(context.Background(), source)
+					Expect(err).To(HaveOccurred())
+					Expect(err.Error()).To(ContainSubstring("certificate"))
+					Expect(tlsTokenServer.ReceivedRequests()).To(BeEmpty())
+					Expect(gateServer.ReceivedRequests()).To(BeEmpty())
+				})
+			})
+		})
+
+		Context("Given a username and password", func() {
+			loginHandler := func(session string) http.HandlerFunc {
+				return ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/login"),
+					ghttp.VerifyFormKV("username", "some-user"),
+					ghttp.VerifyFormKV("password", "some-password"),
+					ghttp.RespondWith(302, nil, http.Header{
+						"Location":   {"/"},
+						"Set-Cookie": {"SESSION=" + session + "; Path=/; HttpOnly"},
+					}),
+				)
+			}
+
+			BeforeEach(func() {
+				source.Auth = concourse.Auth{
+					Type:     "basic",
+					Username: "some-user",
+					Password: "some-password",
+				}
+			})
+
+			It("logs in once and reuses the session cookie", func() {
+				gateServer.AppendHandlers(
+					loginHandler("first-session"),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/applications/existent_app"),
+						ghttp.VerifyHeaderKV("Cookie", "SESSION=first-session"),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/applications/existent_app/pipelineConfigs"),
+						ghttp.VerifyHeaderKV("Cookie", "SESSION=first-session"),
+						ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}}),
+					),
+				)
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+			})
+
+			It("logs in again once when the session has expired", func() {
+				gateServer.AppendHandlers(
+					loginHandler("first-session"),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/applications/existent_app"),
+						ghttp.VerifyHeaderKV("Cookie", "SESSION=first-session"),
+						ghttp.RespondWith(401, nil),
+					),
+					loginHandler("second-session"),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/applications/existent_app"),
+						ghttp.VerifyHeaderKV("Cookie", "SESSION=second-session"),
+						ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/applications/existent_app/pipelineConfigs"),
+						ghttp.VerifyHeaderKV("Cookie", "SESSION=second-session"),
+						ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}}),
+					),
+				)
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(gateServer.ReceivedRequests()).To(HaveLen(5))
+			})
+
+			It("returns an error when gate rejects the credentials", func() {
+				gateServer.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/login"),
+						ghttp.RespondWith(302, nil, http.Header{"Location": {"/login?error"}}),
+					),
+				)
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).To(HaveOccurred())
+				Expect(err.Error()).To(ContainSubstring("spinnaker login failed for user some-user"))
+			})
+		})
+
+		Context("Given an unknown auth type", func() {
+			It("returns an error", func() {
+				source.Auth = concourse.Auth{Type: "kerberos"}
+
+				_, err := spinnaker.NewClient(context.Background(), source)
+				Expect(err).To(MatchError("unsupported auth type: kerberos"))
+			})
 		})
 	})
 })
@@ -0,0 +1,123 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+var (
+	ErrApplicationNotFound = errors.New("spinnaker application not found")
+	ErrPipelineNotFound    = errors.New("spinnaker pipeline not found")
+	ErrExecutionNotFound   = errors.New("pipeline execution not found")
+)
+
+// APIError is returned when gate responds with an error status code. Errors
+// for well known failures wrap one of the sentinel errors above, so they can
+// be matched with errors.Is.
+type APIError struct {
+	StatusCode int
+	Exception  string // class of the exception thrown by gate, if any
+	Message    string
+	Body       string // raw response body, when it is not a gate error
+	Method     string
+	URL        string
+	Err        error
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("spinnaker api responded with status code: %d", e.StatusCode)
+	if e.Exception != "" {
+		msg += fmt.Sprintf(", exception: %s", e.Exception)
+	}
+	if e.Message != "" {
+		msg += fmt.Sprintf(", message: %s", e.Message)
+	} else if e.Body != "" {
+		msg += fmt.Sprintf(", body: %s", e.Body)
+	}
+	if e.URL != "" {
+		msg += fmt.Sprintf(" (%s %s)", e.Method, e.URL)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s", e.Err, msg)
+	}
+	return msg
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// reads the body of a successful response, or turns an error response into
+// an APIError wrapping notFound if gate responded with a 404
+func readResponse(response *http.Response, notFound error) ([]byte, error) {
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, err
+	}
+	if response.StatusCode < 400 {
+		return body, nil
+	}
+
+	apiErr := &APIError{
+		StatusCode: response.StatusCode,
+		Method:     response.Request.Method,
+		URL:        response.Request.URL.String(),
+	}
+	if response.StatusCode == http.StatusNotFound {
+		apiErr.Err = notFound
+	}
+
+	var gateError struct {
+		Exception string `json:"exception"`
+		Message   string `json:"message"`
+	}
+	if json.Unmarshal(body, &gateError) == nil && (gateError.Exception != "" || gateError.Message != "") {
+		apiErr.Exception = gateError.Exception
+		apiErr.Message = gateError.Message
+	} else {
+		apiErr.Body = strings.TrimSpace(string(body))
+	}
+	return nil, apiErr
+}
+
+// Hint suggests how to fix a failed request to gate, or returns an empty
+// string if there is nothing to suggest.
+func Hint(err error) string {
+	switch {
+	case errors.Is(err, ErrApplicationNotFound):
+		return "check that spinnaker_application is the name of an existing Spinnaker application"
+	case errors.Is(err, ErrPipelineNotFound):
+		return "check that spinnaker_pipeline is the name of a pipeline in the Spinnaker application"
+	case errors.Is(err, ErrExecutionNotFound):
+		return "the pipeline execution may have been deleted from Spinnaker"
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return ""
+	}
+	switch {
+	case apiErr.StatusCode == http.StatusUnauthorized:
+		return "check the credentials configured for the resource"
+	case apiErr.StatusCode == http.StatusForbidden:
+		return "check that the configured user is allowed to access the Spinnaker application"
+	case apiErr.StatusCode >= 500:
+		return "Spinnaker failed to handle the request, check the health of the Spinnaker api and try again"
+	}
+	return ""
+}
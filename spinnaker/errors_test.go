@@ -0,0 +1,81 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker/gatetest"
+)
+
+var _ = Describe("Errors", func() {
+	Context("When gate responds with an error", func() {
+		var (
+			gateServer *ghttp.Server
+			spinClient spinnaker.SpinClient
+		)
+
+		BeforeEach(func() {
+			pipeline := gatetest.NewPipeline("existent_app", "existent_pipeline")
+			gateServer = ghttp.NewServer()
+			gateServer.AppendHandlers(pipeline.ClientHandlers()...)
+
+			var err error
+			spinClient, err = spinnaker.NewClient(context.Background(), pipeline.Source(gateServer.URL()))
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			gateServer.Close()
+		})
+
+		It("returns ErrExecutionNotFound for an unknown execution", func() {
+			gateServer.AppendHandlers(ghttp.RespondWithJSONEncoded(404, map[string]interface{}{
+				"exception": "com.netflix.spinnaker.kork.web.exceptions.NotFoundException",
+				"message":   "Pipeline not found (id: ABC123)",
+			}))
+
+			_, err := spinClient.GetPipelineExecution(context.Background(), "ABC123")
+			Expect(errors.Is(err, spinnaker.ErrExecutionNotFound)).To(BeTrue())
+			Expect(spinnaker.Hint(err)).To(ContainSubstring("deleted"))
+		})
+
+		It("keeps the body when gate does not respond with a json error", func() {
+			gateServer.AppendHandlers(ghttp.RespondWith(403, "<html>Forbidden</html>\n"))
+
+			_, err := spinClient.GetPipelineExecution(context.Background(), "ABC123")
+
+			var apiErr *spinnaker.APIError
+			Expect(errors.As(err, &apiErr)).To(BeTrue())
+			Expect(apiErr.StatusCode).To(Equal(403))
+			Expect(apiErr.Method).To(Equal("GET"))
+			Expect(apiErr.Body).To(Equal("<html>Forbidden</html>"))
+			Expect(errors.Is(err, spinnaker.ErrExecutionNotFound)).To(BeFalse())
+			Expect(err.Error()).To(Equal("spinnaker api responded with status code: 403, body: <html>Forbidden</html> (GET " + gateServer.URL() + "/pipelines/ABC123)"))
+		})
+	})
+
+	Describe("Hint", func() {
+		It("explains common failures", func() {
+			Expect(spinnaker.Hint(fmt.Errorf("%w: foo", spinnaker.ErrPipelineNotFound))).To(ContainSubstring("spinnaker_pipeline"))
+			Expect(spinnaker.Hint(&spinnaker.APIError{StatusCode: 404, Err: spinnaker.ErrApplicationNotFound})).To(ContainSubstring("spinnaker_application"))
+			Expect(spinnaker.Hint(&spinnaker.APIError{StatusCode: 401})).To(ContainSubstring("credentials"))
+			Expect(spinnaker.Hint(&spinnaker.APIError{StatusCode: 503})).To(ContainSubstring("try again"))
+			Expect(spinnaker.Hint(&spinnaker.APIError{StatusCode: 422})).To(BeEmpty())
+			Expect(spinnaker.Hint(errors.New("something else"))).To(BeEmpty())
+		})
+	})
+})
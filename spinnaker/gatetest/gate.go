@@ -0,0 +1,75 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+// Package gatetest provides the responses of a fake gate for the tests of
+// the resource. It does not assert anything itself: requests it does not
+// expect are answered with a 404, which fails the client under test.
+package gatetest
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+)
+
+// Pipeline is a pipeline of an application as served by a fake gate.
+type Pipeline struct {
+	Application string
+
+	// Config is served as the config of the pipeline, and may be changed
+	// until a client is created, for example to declare parameters.
+	Config map[string]interface{}
+}
+
+// NewPipeline returns the pipeline of the application, with the ID
+// <name>-config-id.
+func NewPipeline(application, name string) *Pipeline {
+	return &Pipeline{
+		Application: application,
+		Config: map[string]interface{}{
+			"name": name,
+			"id":   name + "-config-id",
+		},
+	}
+}
+
+// ClientHandlers answer the lookups of the application and the pipeline
+// configs made by spinnaker.NewClient, in that order. Tests append them to
+// their fake gate before the handlers for the requests they expect.
+func (p *Pipeline) ClientHandlers() []http.HandlerFunc {
+	return []http.HandlerFunc{
+		func(w http.ResponseWriter, req *http.Request) {
+			respond(w, req, "/applications/"+p.Application, map[string]interface{}{"name": p.Application})
+		},
+		func(w http.ResponseWriter, req *http.Request) {
+			respond(w, req, "/applications/"+p.Application+"/pipelineConfigs", []map[string]interface{}{p.Config})
+		},
+	}
+}
+
+// Source configures the resource for the pipeline on the fake gate at the
+// url, without authentication.
+func (p *Pipeline) Source(url string) concourse.Source {
+	return concourse.Source{
+		SpinnakerAPI:         url,
+		SpinnakerApplication: p.Application,
+		SpinnakerPipeline:    p.Config["name"].(string),
+		Auth:                 concourse.Auth{Type: "none"},
+	}
+}
+
+func respond(w http.ResponseWriter, req *http.Request, path string, body interface{}) {
+	if req.Method != http.MethodGet || req.URL.Path != path {
+		http.NotFound(w, req)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
@@ -9,9 +9,300 @@ Unless required by applicable law or agreed to in writing, software distributed
 */
 package spinnaker
 
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type ExecutionStatus string
+
+// Reference: https://github.com/spinnaker/orca/blob/master/orca-core/src/main/java/com/netflix/spinnaker/orca/ExecutionStatus.java
+const (
+	StatusNotStarted     ExecutionStatus = "NOT_STARTED"
+	StatusRunning        ExecutionStatus = "RUNNING"
+	StatusPaused         ExecutionStatus = "PAUSED"
+	StatusSuspended      ExecutionStatus = "SUSPENDED"
+	StatusSucceeded      ExecutionStatus = "SUCCEEDED"
+	StatusFailedContinue ExecutionStatus = "FAILED_CONTINUE"
+	StatusTerminal       ExecutionStatus = "TERMINAL"
+	StatusCanceled       ExecutionStatus = "CANCELED"
+	StatusRedirect       ExecutionStatus = "REDIRECT"
+	StatusStopped        ExecutionStatus = "STOPPED"
+	StatusSkipped        ExecutionStatus = "SKIPPED"
+	StatusBuffered       ExecutionStatus = "BUFFERED"
+)
+
+// IsTerminal reports whether an execution or stage with this status has
+// finished and will not change status anymore.
+func (s ExecutionStatus) IsTerminal() bool {
+	switch s {
+	case StatusSucceeded, StatusFailedContinue, StatusTerminal, StatusCanceled, StatusStopped, StatusSkipped:
+		return true
+	}
+	return false
+}
+
+// Matches reports whether the status is one of statuses. Any status matches
+// when there are none.
+func (s ExecutionStatus) Matches(statuses []string) bool {
+	if len(statuses) == 0 {
+		return true
+	}
+	for _, status := range statuses {
+		if string(s) == status {
+			return true
+		}
+	}
+	return false
+}
+
+// IsSuccessful reports whether the status is a successful completion.
+func (s ExecutionStatus) IsSuccessful() bool {
+	switch s {
+	case StatusSucceeded, StatusStopped, StatusSkipped:
+		return true
+	}
+	return false
+}
+
+type PipelineConfig struct {
+	ID              string            `json:"id"`
+	Name            string            `json:"name"`
+	Application     string            `json:"application"`
+	ParameterConfig []ParameterConfig `json:"parameterConfig"`
+}
+
+// A parameter declared by a pipeline.
+type ParameterConfig struct {
+	Name        string            `json:"name"`
+	Label       string            `json:"label"`
+	Description string            `json:"description"`
+	Default     ParameterValue    `json:"default"`
+	Required    bool              `json:"required"`
+	HasOptions  bool              `json:"hasOptions"`
+	Options     []ParameterOption `json:"options"`
+}
+
+type ParameterOption struct {
+	Value ParameterValue `json:"value"`
+}
+
+// ParameterValue is the default or an option of a parameter. Spinnaker
+// treats them as strings, but pipeline configs saved through the api may hold
+// numbers or booleans, which are kept as their JSON text, e.g. "3" or "true".
+type ParameterValue string
+
+func (v *ParameterValue) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		*v = ParameterValue(s)
+		return nil
+	}
+	*v = ParameterValue(data)
+	return nil
+}
+
+// Filters for the executions returned by gate, see
+// https://www.spinnaker.io/reference/api/docs.html#api-Executionscontroller-getLatestExecutionsByConfigIdsUsingGET
+type ExecutionQuery struct {
+	PipelineConfigIDs []string // defaults to the configured pipeline
+	Statuses          []string
+	Limit             int
+	Expand            bool // include the stages of each execution
+}
+
 type PipelineExecution struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	BuildTime uint64 `json:"buildTime"`
-	Status    string `json:"status"`
+	ID                 string          `json:"id"`
+	Name               string          `json:"name"`
+	Application        string          `json:"application"`
+	PipelineConfigID   string          `json:"pipelineConfigId"`
+	Status             ExecutionStatus `json:"status"`
+	BuildTime          int64           `json:"buildTime"`
+	StartTime          int64           `json:"startTime"`
+	EndTime            int64           `json:"endTime"`
+	Canceled           bool            `json:"canceled"`
+	CanceledBy         string          `json:"canceledBy,omitempty"`
+	CancellationReason string          `json:"cancellationReason,omitempty"`
+	Authentication     Authentication  `json:"authentication"`
+	Trigger            Trigger         `json:"trigger"`
+	Stages             []Stage         `json:"stages"`
+}
+
+// Duration returns how long the execution ran, or zero while it has not
+// finished.
+func (e PipelineExecution) Duration() time.Duration {
+	if e.StartTime == 0 || e.EndTime == 0 {
+		return 0
+	}
+	return time.Duration(e.EndTime-e.StartTime) * time.Millisecond
+}
+
+type Authentication struct {
+	User            string   `json:"user"`
+	AllowedAccounts []string `json:"allowedAccounts"`
+}
+
+type Trigger struct {
+	Type                      string                 `json:"type"`
+	User                      string                 `json:"user"`
+	Parameters                map[string]interface{} `json:"parameters"`
+	Artifacts                 []Artifact             `json:"artifacts"`
+	ResolvedExpectedArtifacts []ExpectedArtifact     `json:"resolvedExpectedArtifacts,omitempty"`
+	ParentExecution           *PipelineExecution     `json:"parentExecution,omitempty"`
+}
+
+// An artifact the pipeline expects, bound to the artifact it was resolved to.
+type ExpectedArtifact struct {
+	ID            string    `json:"id"`
+	BoundArtifact *Artifact `json:"boundArtifact,omitempty"`
+}
+
+// the stage outputs orca records produced and bound artifacts under
+var artifactOutputKeys = []string{"artifacts", "outputs.createdArtifacts", "outputs.boundArtifacts"}
+
+// Artifacts returns the artifacts the execution was triggered with, bound to
+// its expected artifacts, or produced by its stages, without duplicates.
+func (e PipelineExecution) Artifacts() []Artifact {
+	var artifacts []Artifact
+	seen := map[string]bool{}
+	add := func(artifact Artifact) {
+		key := strings.Join([]string{artifact.Type, artifact.Name, artifact.Version, artifact.Location, artifact.Reference}, "\x00")
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		artifacts = append(artifacts, artifact)
+	}
+
+	for _, artifact := range e.Trigger.Artifacts {
+		add(artifact)
+	}
+	for _, expectedArtifact := range e.Trigger.ResolvedExpectedArtifacts {
+		if expectedArtifact.BoundArtifact != nil {
+			add(*expectedArtifact.BoundArtifact)
+		}
+	}
+	for _, stage := range e.Stages {
+		for _, key := range artifactOutputKeys {
+			output, ok := stage.Outputs[key]
+			if !ok {
+				continue
+			}
+			// outputs are untyped, so round trip them through JSON
+			contents, err := json.Marshal(output)
+			if err != nil {
+				continue
+			}
+			var stageArtifacts []Artifact
+			if json.Unmarshal(contents, &stageArtifacts) != nil {
+				continue
+			}
+			for _, artifact := range stageArtifacts {
+				add(artifact)
+			}
+		}
+	}
+	return artifacts
+}
+
+type Stage struct {
+	ID                   string                 `json:"id"`
+	RefID                string                 `json:"refId"`
+	Type                 string                 `json:"type"`
+	Name                 string                 `json:"name"`
+	Status               ExecutionStatus        `json:"status"`
+	StartTime            int64                  `json:"startTime"`
+	EndTime              int64                  `json:"endTime"`
+	ParentStageID        string                 `json:"parentStageId,omitempty"`
+	SyntheticStageOwner  string                 `json:"syntheticStageOwner,omitempty"`
+	RequisiteStageRefIDs []string               `json:"requisiteStageRefIds"`
+	Context              map[string]interface{} `json:"context"`
+	Outputs              map[string]interface{} `json:"outputs"`
+}
+
+// Duration returns how long the stage ran, or zero while it has not finished.
+func (s Stage) Duration() time.Duration {
+	if s.StartTime == 0 || s.EndTime == 0 {
+		return 0
+	}
+	return time.Duration(s.EndTime-s.StartTime) * time.Millisecond
+}
+
+// ErrorMessages collects the error messages orca records in the context of a
+// failed stage: the exception details of the stage itself and the exceptions
+// of the clouddriver (kato) tasks it ran.
+func (s Stage) ErrorMessages() []string {
+	var messages []string
+
+	if exception, ok := s.Context["exception"].(map[string]interface{}); ok {
+		if details, ok := exception["details"].(map[string]interface{}); ok {
+			if errs, ok := details["errors"].([]interface{}); ok {
+				for _, e := range errs {
+					if message, ok := e.(string); ok && message != "" {
+						messages = append(messages, message)
+					}
+				}
+			}
+			if message, ok := details["error"].(string); ok && message != "" && len(messages) == 0 {
+				messages = append(messages, message)
+			}
+		}
+	}
+	if message, ok := s.Context["error"].(string); ok && message != "" {
+		messages = append(messages, message)
+	}
+	if tasks, ok := s.Context["kato.tasks"].([]interface{}); ok {
+		for _, t := range tasks {
+			task, ok := t.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if exception, ok := task["exception"].(map[string]interface{}); ok {
+				if message, ok := exception["message"].(string); ok && message != "" {
+					messages = append(messages, message)
+				}
+			}
+		}
+	}
+	return messages
+}
+
+const StageTypeManualJudgment = "manualJudgment"
+
+// WaitingManualJudgments returns the manual judgment stages of the execution
+// that are waiting for a judgment.
+func (e PipelineExecution) WaitingManualJudgments() []Stage {
+	var stages []Stage
+	for _, stage := range e.Stages {
+		if stage.Type == StageTypeManualJudgment && stage.Status == StatusRunning {
+			stages = append(stages, stage)
+		}
+	}
+	return stages
+}
+
+const (
+	JudgmentContinue = "continue"
+	JudgmentStop     = "stop"
+)
+
+// Judgment is sent to gate to approve (continue) or reject (stop) a manual
+// judgment stage.
+type Judgment struct {
+	JudgmentStatus string `json:"judgmentStatus"`
+	JudgmentInput  string `json:"judgmentInput,omitempty"`
+}
+
+// Reference: https://www.spinnaker.io/reference/artifacts/#format
+type Artifact struct {
+	Type            string                 `json:"type"`
+	Name            string                 `json:"name,omitempty"`
+	Version         string                 `json:"version,omitempty"`
+	Location        string                 `json:"location,omitempty"`
+	Reference       string                 `json:"reference,omitempty"`
+	ArtifactAccount string                 `json:"artifactAccount,omitempty"`
+	Provenance      string                 `json:"provenance,omitempty"`
+	UUID            string                 `json:"uuid,omitempty"`
+	Metadata        map[string]interface{} `json:"metadata,omitempty"`
 }
@@ -0,0 +1,178 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/ginkgo/extensions/table"
+	. "github.com/onsi/gomega"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+var _ = Describe("Models", func() {
+	DescribeTable("ExecutionStatus",
+		func(status spinnaker.ExecutionStatus, terminal, successful bool) {
+			Expect(status.IsTerminal()).To(Equal(terminal))
+			Expect(status.IsSuccessful()).To(Equal(successful))
+		},
+		Entry("NOT_STARTED", spinnaker.StatusNotStarted, false, false),
+		Entry("RUNNING", spinnaker.StatusRunning, false, false),
+		Entry("PAUSED", spinnaker.StatusPaused, false, false),
+		Entry("SUSPENDED", spinnaker.StatusSuspended, false, false),
+		Entry("BUFFERED", spinnaker.StatusBuffered, false, false),
+		Entry("REDIRECT", spinnaker.StatusRedirect, false, false),
+		Entry("SUCCEEDED", spinnaker.StatusSucceeded, true, true),
+		Entry("STOPPED", spinnaker.StatusStopped, true, true),
+		Entry("SKIPPED", spinnaker.StatusSkipped, true, true),
+		Entry("FAILED_CONTINUE", spinnaker.StatusFailedContinue, true, false),
+		Entry("TERMINAL", spinnaker.StatusTerminal, true, false),
+		Entry("CANCELED", spinnaker.StatusCanceled, true, false),
+		Entry("an unknown status", spinnaker.ExecutionStatus("SOMETHING_NEW"), false, false),
+	)
+
+	It("matches a status against a list of statuses, or any status without one", func() {
+		Expect(spinnaker.StatusSucceeded.Matches([]string{"TERMINAL", "SUCCEEDED"})).To(BeTrue())
+		Expect(spinnaker.StatusRunning.Matches([]string{"TERMINAL", "SUCCEEDED"})).To(BeFalse())
+		Expect(spinnaker.StatusRunning.Matches(nil)).To(BeTrue())
+	})
+
+	Context("When unmarshalling a pipeline execution returned by gate", func() {
+		var pipelineExecution spinnaker.PipelineExecution
+
+		BeforeEach(func() {
+			body, err := ioutil.ReadFile("../integration/fixtures/get_pipelines_response.json")
+			Expect(err).ToNot(HaveOccurred())
+
+			err = json.Unmarshal(body, &pipelineExecution)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("parses the execution", func() {
+			Expect(pipelineExecution.ID).To(Equal("01CXDAAJR4G27W7T01M6BA6H1E"))
+			Expect(pipelineExecution.Name).To(Equal("bar"))
+			Expect(pipelineExecution.Application).To(Equal("some-application"))
+			Expect(pipelineExecution.Status).To(Equal(spinnaker.StatusSucceeded))
+			Expect(pipelineExecution.BuildTime).To(Equal(int64(1543414041348)))
+			Expect(pipelineExecution.StartTime).To(Equal(int64(1543414041364)))
+			Expect(pipelineExecution.EndTime).To(Equal(int64(1543414041439)))
+			Expect(pipelineExecution.Authentication.User).To(Equal("some-user"))
+			Expect(pipelineExecution.Authentication.AllowedAccounts).To(ConsistOf("my-kubernetes-account"))
+		})
+
+		It("parses the trigger", func() {
+			Expect(pipelineExecution.Trigger.Type).To(Equal("concourse-resource"))
+			Expect(pipelineExecution.Trigger.User).To(Equal("some-user"))
+			Expect(pipelineExecution.Trigger.Parameters).To(BeEmpty())
+			Expect(pipelineExecution.Trigger.ParentExecution).To(BeNil())
+		})
+
+		It("parses the stages", func() {
+			Expect(pipelineExecution.Stages).ToNot(BeEmpty())
+
+			stage := pipelineExecution.Stages[0]
+			Expect(stage.RefID).To(Equal("1<1"))
+			Expect(stage.Name).To(Equal("Check precondition (expression)"))
+			Expect(stage.Type).To(Equal("checkPreconditions"))
+			Expect(stage.Status).To(Equal(spinnaker.StatusSucceeded))
+			Expect(stage.StartTime).To(Equal(int64(1543414041383)))
+			Expect(stage.EndTime).To(Equal(int64(1543414041413)))
+			Expect(stage.Context).To(HaveKeyWithValue("preconditionType", "expression"))
+		})
+	})
+
+	It("finds the manual judgment stages waiting for a judgment", func() {
+		pipelineExecution := spinnaker.PipelineExecution{
+			Stages: []spinnaker.Stage{
+				{ID: "S1", Type: "manualJudgment", Status: spinnaker.StatusSucceeded},
+				{ID: "S2", Type: "manualJudgment", Status: spinnaker.StatusRunning},
+				{ID: "S3", Type: "deployManifest", Status: spinnaker.StatusRunning},
+				{ID: "S4", Type: "manualJudgment", Status: spinnaker.StatusNotStarted},
+			},
+		}
+
+		stages := pipelineExecution.WaitingManualJudgments()
+		Expect(stages).To(HaveLen(1))
+		Expect(stages[0].ID).To(Equal("S2"))
+	})
+
+	It("computes the duration of finished executions", func() {
+		Expect(spinnaker.PipelineExecution{StartTime: 1000, EndTime: 61000}.Duration()).To(Equal(time.Minute))
+		Expect(spinnaker.PipelineExecution{StartTime: 1000}.Duration()).To(BeZero())
+	})
+
+	It("computes the duration of finished stages", func() {
+		Expect(spinnaker.Stage{StartTime: 1000, EndTime: 193000}.Duration()).To(Equal(3*time.Minute + 12*time.Second))
+		Expect(spinnaker.Stage{StartTime: 1000}.Duration()).To(BeZero())
+	})
+
+	It("collects the error messages from the context of a failed stage", func() {
+		var stage spinnaker.Stage
+		err := json.Unmarshal([]byte(`{
+			"context": {
+				"exception": {
+					"details": {
+						"error": "Unexpected Task Failure",
+						"errors": ["Deployment failed", "Pods crashed"]
+					}
+				},
+				"kato.tasks": [
+					{"exception": {"message": "Quota exceeded"}},
+					{"status": {"completed": true}}
+				]
+			}
+		}`), &stage)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(stage.ErrorMessages()).To(Equal([]string{"Deployment failed", "Pods crashed", "Quota exceeded"}))
+	})
+
+	It("falls back to the exception error of a failed stage", func() {
+		stage := spinnaker.Stage{
+			Context: map[string]interface{}{
+				"exception": map[string]interface{}{
+					"details": map[string]interface{}{"error": "Unexpected Task Failure"},
+				},
+			},
+		}
+
+		Expect(stage.ErrorMessages()).To(Equal([]string{"Unexpected Task Failure"}))
+	})
+
+	It("collects the artifacts of an execution without duplicates", func() {
+		var pipelineExecution spinnaker.PipelineExecution
+		err := json.Unmarshal([]byte(`{
+			"trigger": {
+				"artifacts": [{"type": "docker/image", "name": "gcr.io/app", "reference": "gcr.io/app:v1"}],
+				"resolvedExpectedArtifacts": [
+					{"id": "expected-1", "boundArtifact": {"type": "docker/image", "name": "gcr.io/app", "reference": "gcr.io/app:v1"}},
+					{"id": "expected-2", "boundArtifact": {"type": "gcs/object", "name": "gs://bucket/manifest.yml", "reference": "gs://bucket/manifest.yml"}},
+					{"id": "expected-3"}
+				]
+			},
+			"stages": [
+				{"outputs": {"artifacts": [{"type": "aws/image", "name": "ami-app", "reference": "ami-123", "location": "us-east-1"}]}},
+				{"outputs": {"outputs.createdArtifacts": [{"type": "kubernetes/deployment", "name": "app", "version": "v002", "location": "default"}]}},
+				{"outputs": {"artifacts": "not a list"}}
+			]
+		}`), &pipelineExecution)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(pipelineExecution.Artifacts()).To(Equal([]spinnaker.Artifact{
+			{Type: "docker/image", Name: "gcr.io/app", Reference: "gcr.io/app:v1"},
+			{Type: "gcs/object", Name: "gs://bucket/manifest.yml", Reference: "gs://bucket/manifest.yml"},
+			{Type: "aws/image", Name: "ami-app", Reference: "ami-123", Location: "us-east-1"},
+			{Type: "kubernetes/deployment", Name: "app", Version: "v002", Location: "default"},
+		}))
+	})
+})
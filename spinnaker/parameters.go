@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParameterError lists every problem found checking trigger parameters
+// against the parameterConfig of a pipeline.
+type ParameterError struct {
+	Pipeline string
+	Problems []string
+}
+
+func (e *ParameterError) Error() string {
+	return fmt.Sprintf("invalid trigger parameters for pipeline '%s': %s", e.Pipeline, strings.Join(e.Problems, "; "))
+}
+
+// ApplyParameterConfig checks params against the parameters the pipeline
+// declares: defaults are filled in for missing parameters, required ones must
+// have a value and parameters with options must have one of them. It returns
+// the params to send along with the names of the params the pipeline does not
+// declare, sorted. A pipeline that declares no parameters may still read any
+// of them in its expressions, so none of them are reported for it.
+func (p PipelineConfig) ApplyParameterConfig(params map[string]interface{}) (map[string]interface{}, []string, error) {
+	applied := make(map[string]interface{}, len(params))
+	for key, value := range params {
+		applied[key] = value
+	}
+
+	var problems []string
+	declared := map[string]bool{}
+	for _, parameter := range p.ParameterConfig {
+		declared[parameter.Name] = true
+
+		value, ok := applied[parameter.Name]
+		if !ok && parameter.Default != "" {
+			value, ok = string(parameter.Default), true
+			applied[parameter.Name] = value
+		}
+		if !ok || value == nil || parameterValue(value) == "" {
+			if parameter.Required {
+				problems = append(problems, fmt.Sprintf("missing required parameter %s", parameter.Name))
+			}
+			continue
+		}
+
+		if parameter.HasOptions && len(parameter.Options) > 0 {
+			options := make([]string, 0, len(parameter.Options))
+			valid := false
+			for _, option := range parameter.Options {
+				options = append(options, string(option.Value))
+				if parameterValue(value) == string(option.Value) {
+					valid = true
+				}
+			}
+			if !valid {
+				problems = append(problems, fmt.Sprintf("parameter %s is %q, expected one of: %s", parameter.Name, parameterValue(value), strings.Join(options, ", ")))
+			}
+		}
+	}
+
+	var unknown []string
+	for key := range applied {
+		if len(declared) > 0 && !declared[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	if len(problems) > 0 {
+		return nil, unknown, &ParameterError{
+			Pipeline: p.Name,
+			Problems: problems,
+		}
+	}
+	return applied, unknown, nil
+}
+
+// parameterValue is how spinnaker sees a param value, as parameters are
+// strings in its pipeline configs.
+func parameterValue(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case json.Number:
+		return v.String()
+	}
+	contents, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(contents)
+}
@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"encoding/json"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+var _ = Describe("Parameters", func() {
+	var pipelineConfig spinnaker.PipelineConfig
+
+	BeforeEach(func() {
+		err := json.Unmarshal([]byte(`{
+			"name": "deploy",
+			"parameterConfig": [
+				{"name": "version", "required": true},
+				{"name": "env", "required": true, "default": "staging", "hasOptions": true, "options": [{"value": "staging"}, {"value": "prod"}]},
+				{"name": "replicas", "hasOptions": true, "options": [{"value": "1"}, {"value": "3"}]},
+				{"name": "notes"}
+			]
+		}`), &pipelineConfig)
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	It("fills in defaults and reports undeclared params", func() {
+		params, unknown, err := pipelineConfig.ApplyParameterConfig(map[string]interface{}{
+			"version":  "1.2.3",
+			"replicas": json.Number("3"),
+			"verison":  "typo",
+			"extra":    true,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params).To(Equal(map[string]interface{}{
+			"version":  "1.2.3",
+			"env":      "staging",
+			"replicas": json.Number("3"),
+			"verison":  "typo",
+			"extra":    true,
+		}))
+		Expect(unknown).To(Equal([]string{"extra", "verison"}))
+	})
+
+	It("reports every missing required param and value outside of the options", func() {
+		_, _, err := pipelineConfig.ApplyParameterConfig(map[string]interface{}{
+			"env":      "prdo",
+			"replicas": 2,
+		})
+
+		parameterErr, ok := err.(*spinnaker.ParameterError)
+		Expect(ok).To(BeTrue())
+		Expect(parameterErr.Problems).To(Equal([]string{
+			"missing required parameter version",
+			`parameter env is "prdo", expected one of: staging, prod`,
+			`parameter replicas is "2", expected one of: 1, 3`,
+		}))
+		Expect(err.Error()).To(HavePrefix("invalid trigger parameters for pipeline 'deploy': missing required parameter version; "))
+	})
+
+	It("treats empty values of required params as missing", func() {
+		_, _, err := pipelineConfig.ApplyParameterConfig(map[string]interface{}{
+			"version": "",
+		})
+
+		Expect(err).To(MatchError("invalid trigger parameters for pipeline 'deploy': missing required parameter version"))
+	})
+
+	It("compares numeric and boolean defaults and options by their JSON text", func() {
+		var pipelineConfig spinnaker.PipelineConfig
+		err := json.Unmarshal([]byte(`{
+			"name": "scale",
+			"parameterConfig": [
+				{"name": "replicas", "default": 3, "hasOptions": true, "options": [{"value": 1}, {"value": 3}]},
+				{"name": "canary", "default": false}
+			]
+		}`), &pipelineConfig)
+		Expect(err).ToNot(HaveOccurred())
+
+		params, _, err := pipelineConfig.ApplyParameterConfig(map[string]interface{}{})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params).To(Equal(map[string]interface{}{"replicas": "3", "canary": "false"}))
+
+		_, _, err = pipelineConfig.ApplyParameterConfig(map[string]interface{}{"replicas": json.Number("2")})
+		Expect(err).To(MatchError(`invalid trigger parameters for pipeline 'scale': parameter replicas is "2", expected one of: 1, 3`))
+	})
+
+	It("accepts any params when the pipeline declares none", func() {
+		params, unknown, err := spinnaker.PipelineConfig{}.ApplyParameterConfig(map[string]interface{}{"foo": "bar"})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(params).To(Equal(map[string]interface{}{"foo": "bar"}))
+		Expect(unknown).To(BeEmpty())
+	})
+})
@@ -0,0 +1,177 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+)
+
+const (
+	defaultRetryAttempts  = 3
+	defaultRetryBaseDelay = "1s"
+	defaultRetryMaxDelay  = "30s"
+)
+
+// RetryPolicy decides how often and how long to wait before sending a
+// request to gate again after a transient failure.
+type RetryPolicy struct {
+	Attempts  int // including the first attempt
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+func NewRetryPolicy(source concourse.Source) (RetryPolicy, error) {
+	policy := RetryPolicy{Attempts: source.RetryAttempts}
+	if policy.Attempts <= 0 {
+		policy.Attempts = defaultRetryAttempts
+	}
+
+	var err error
+	policy.BaseDelay, err = ParseDurationDefault(source.RetryBaseDelay, defaultRetryBaseDelay)
+	if err != nil {
+		return RetryPolicy{}, err
+	}
+	policy.MaxDelay, err = ParseDurationDefault(source.RetryMaxDelay, defaultRetryMaxDelay)
+	if err != nil {
+		return RetryPolicy{}, err
+	}
+	return policy, nil
+}
+
+// delay before the given retry, doubling from the base delay up to the max delay
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// retries requests that failed with a connection error, a 502, 503 or 504,
+// or were rate limited with a 429. Requests that are not idempotent, like
+// triggering a pipeline, are only retried when gate cannot have acted on
+// them: the connection was never established or the request was rate limited.
+type retryTransport struct {
+	base   http.RoundTripper
+	policy RetryPolicy
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	idempotent := req.Method != "POST" && req.Method != "PATCH"
+	replayable := req.Body == nil || req.GetBody != nil
+
+	for attempt := 1; ; attempt++ {
+		attemptReq := req
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		response, err := t.base.RoundTrip(attemptReq)
+		if attempt >= t.policy.Attempts || !replayable {
+			return response, err
+		}
+
+		var delay time.Duration
+		switch {
+		case err != nil:
+			if req.Context().Err() != nil || !(isDialError(err) || idempotent && isConnectionError(err)) {
+				return response, err
+			}
+			delay = t.policy.backoff(attempt)
+		case response.StatusCode == http.StatusTooManyRequests:
+			delay = t.policy.backoff(attempt)
+			if retryAfter, ok := parseRetryAfter(response.Header.Get("Retry-After")); ok {
+				delay = retryAfter
+			}
+			if delay > t.policy.MaxDelay {
+				delay = t.policy.MaxDelay
+			}
+		case idempotent && (response.StatusCode == http.StatusBadGateway ||
+			response.StatusCode == http.StatusServiceUnavailable ||
+			response.StatusCode == http.StatusGatewayTimeout):
+			delay = t.policy.backoff(attempt)
+		default:
+			return response, err
+		}
+
+		if response != nil {
+			response.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+}
+
+// a failed dial means the request never reached gate
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// the connection was reset, closed or timed out while the request was in flight
+func isConnectionError(err error) bool {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	return errors.Is(err, io.EOF) ||
+		errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) ||
+		errors.Is(err, syscall.EPIPE)
+}
+
+// Retry-After is either a number of seconds or an http date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		delay := time.Until(date)
+		if delay < 0 {
+			delay = 0
+		}
+		return delay, true
+	}
+	return 0, false
+}
+
+// ParseDurationDefault parses a duration param, falling back to
+// defaultDuration when it is not set.
+func ParseDurationDefault(stringDuration, defaultDuration string) (time.Duration, error) {
+	if stringDuration == "" {
+		return time.ParseDuration(defaultDuration)
+	}
+	return time.ParseDuration(stringDuration)
+}
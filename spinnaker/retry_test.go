@@ -0,0 +1,172 @@
+/*
+Copyright (C) 2018-Present Pivotal Software, Inc. All rights reserved.
+
+This program and the accompanying materials are made available under the terms of the under the Apache License, Version 2.0 (the "License”); you may not use this file except in compliance with the License. You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software distributed under the License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the License for the specific language governing permissions and limitations under the License.
+*/
+package spinnaker_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	"github.com/pivotal-cf/spinnaker-resource/concourse"
+	"github.com/pivotal-cf/spinnaker-resource/spinnaker"
+)
+
+var _ = Describe("Retries", func() {
+	var (
+		gateServer                                 *ghttp.Server
+		source                                     concourse.Source
+		applicationHandler, pipelineConfigsHandler http.HandlerFunc
+	)
+
+	resetConnection := func(w http.ResponseWriter, req *http.Request) {
+		conn, _, err := w.(http.Hijacker).Hijack()
+		Expect(err).ToNot(HaveOccurred())
+		conn.Close()
+	}
+
+	BeforeEach(func() {
+		applicationHandler = ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", "/applications/existent_app"),
+			ghttp.RespondWithJSONEncoded(200, map[string]interface{}{"name": "existent_app"}),
+		)
+		pipelineConfigsHandler = ghttp.CombineHandlers(
+			ghttp.VerifyRequest("GET", "/applications/existent_app/pipelineConfigs"),
+			ghttp.RespondWithJSONEncoded(200, []map[string]interface{}{{"name": "existent_pipeline"}}),
+		)
+
+		gateServer = ghttp.NewServer()
+		source = concourse.Source{
+			SpinnakerAPI:         gateServer.URL(),
+			SpinnakerApplication: "existent_app",
+			SpinnakerPipeline:    "existent_pipeline",
+			Auth:                 concourse.Auth{Type: "none"},
+			RetryBaseDelay:       "1ms",
+			RetryMaxDelay:        "5ms",
+		}
+	})
+
+	AfterEach(func() {
+		gateServer.Close()
+	})
+
+	Context("When gate is temporarily unavailable", func() {
+		It("retries with backoff until gate responds", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(503, nil),
+				resetConnection,
+				ghttp.RespondWith(504, nil),
+				applicationHandler,
+				pipelineConfigsHandler,
+			)
+			source.RetryAttempts = 4
+
+			_, err := spinnaker.NewClient(context.Background(), source)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(5))
+		})
+
+		It("gives up after the configured number of attempts", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(502, nil),
+				ghttp.RespondWith(502, "bad gateway"),
+			)
+			source.RetryAttempts = 2
+
+			_, err := spinnaker.NewClient(context.Background(), source)
+			Expect(err).To(HaveOccurred())
+			Expect(err.Error()).To(ContainSubstring("status code: 502"))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(2))
+		})
+
+		It("stops waiting to retry when the context is done", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			gateServer.AppendHandlers(
+				ghttp.CombineHandlers(
+					func(http.ResponseWriter, *http.Request) { cancel() },
+					ghttp.RespondWith(503, nil),
+				),
+			)
+			source.RetryAttempts = 3
+			source.RetryBaseDelay = "1h"
+			source.RetryMaxDelay = "1h"
+
+			_, err := spinnaker.NewClient(ctx, source)
+			Expect(errors.Is(err, context.Canceled)).To(BeTrue())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(1))
+		})
+
+		It("does not retry other server errors", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(500, "internal server error"),
+			)
+
+			_, err := spinnaker.NewClient(context.Background(), source)
+			Expect(err).To(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(1))
+		})
+	})
+
+	Context("When triggering a pipeline", func() {
+		var spinClient spinnaker.SpinClient
+
+		BeforeEach(func() {
+			gateServer.AppendHandlers(applicationHandler, pipelineConfigsHandler)
+
+			var err error
+			spinClient, err = spinnaker.NewClient(context.Background(), source)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+		It("does not retry when gate may have received the request", func() {
+			gateServer.AppendHandlers(ghttp.RespondWith(503, nil))
+
+			_, err := spinClient.InvokePipelineExecution(context.Background(), []byte(`{"type":"concourse-resource"}`))
+			Expect(err).To(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+		})
+
+		It("does not retry a reset connection", func() {
+			gateServer.AppendHandlers(resetConnection)
+
+			_, err := spinClient.InvokePipelineExecution(context.Background(), []byte(`{"type":"concourse-resource"}`))
+			Expect(err).To(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(3))
+		})
+
+		It("retries after the delay requested by gate when rate limited", func() {
+			gateServer.AppendHandlers(
+				ghttp.RespondWith(429, nil, http.Header{"Retry-After": {"0"}}),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("POST", "/pipelines/existent_app/existent_pipeline"),
+					ghttp.VerifyJSON(`{"type":"concourse-resource"}`),
+					ghttp.RespondWithJSONEncoded(202, map[string]string{"ref": "/pipelines/ABC123"}),
+				),
+			)
+
+			pipelineExecution, err := spinClient.InvokePipelineExecution(context.Background(), []byte(`{"type":"concourse-resource"}`))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(pipelineExecution.ID).To(Equal("ABC123"))
+			Expect(gateServer.ReceivedRequests()).To(HaveLen(4))
+		})
+	})
+
+	Context("When the retry delays are invalid", func() {
+		It("returns an error", func() {
+			source.RetryBaseDelay = "soon"
+
+			_, err := spinnaker.NewClient(context.Background(), source)
+			Expect(err).To(HaveOccurred())
+			Expect(gateServer.ReceivedRequests()).To(BeEmpty())
+		})
+	})
+})